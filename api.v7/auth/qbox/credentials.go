@@ -0,0 +1,150 @@
+package qbox
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------------------
+
+// 密钥提供者，每次签名前都会调用 Retrieve 获取当前的密钥，以便在不重启进程的情况下更换密钥
+type CredentialsProvider interface {
+	Retrieve() (*Mac, error)
+}
+
+// Retrieve 使 *Mac 本身成为固定密钥的 CredentialsProvider
+func (mac *Mac) Retrieve() (*Mac, error) {
+
+	return mac, nil
+}
+
+// 创建固定密钥的提供者
+func NewStaticProvider(accessKey, secretKey string) CredentialsProvider {
+
+	return NewMac(accessKey, secretKey)
+}
+
+// ----------------------------------------------------------
+
+// 默认的密钥环境变量，与 syncdata 配置项 ak、sk 对应的环境变量相同
+const (
+	DefaultAccessKeyEnv = "QINIU_AK"
+	DefaultSecretKeyEnv = "QINIU_SK"
+)
+
+var ErrNoCredentials = errors.New("no credentials")
+
+// 从环境变量中读取密钥的提供者
+type EnvProvider struct {
+	AccessKeyEnv string // 为空时使用 DefaultAccessKeyEnv
+	SecretKeyEnv string // 为空时使用 DefaultSecretKeyEnv
+}
+
+func NewEnvProvider(accessKeyEnv, secretKeyEnv string) *EnvProvider {
+
+	return &EnvProvider{AccessKeyEnv: accessKeyEnv, SecretKeyEnv: secretKeyEnv}
+}
+
+func (p *EnvProvider) Retrieve() (*Mac, error) {
+
+	akEnv, skEnv := p.AccessKeyEnv, p.SecretKeyEnv
+	if akEnv == "" {
+		akEnv = DefaultAccessKeyEnv
+	}
+	if skEnv == "" {
+		skEnv = DefaultSecretKeyEnv
+	}
+	ak, sk := os.Getenv(akEnv), os.Getenv(skEnv)
+	if ak == "" || sk == "" {
+		return nil, ErrNoCredentials
+	}
+	return &Mac{AccessKey: ak, SecretKey: []byte(sk)}, nil
+}
+
+// ----------------------------------------------------------
+
+// 从文件中读取密钥的提供者，文件内容为 {"ak": "...", "sk": "..."}
+type FileProvider struct {
+	Path string
+}
+
+func NewFileProvider(path string) *FileProvider {
+
+	return &FileProvider{Path: path}
+}
+
+func (p *FileProvider) Retrieve() (*Mac, error) {
+
+	raw, err := ioutil.ReadFile(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	var keys struct {
+		AccessKey string `json:"ak"`
+		SecretKey string `json:"sk"`
+	}
+	if err = json.Unmarshal(raw, &keys); err != nil {
+		return nil, err
+	}
+	if keys.AccessKey == "" || keys.SecretKey == "" {
+		return nil, ErrNoCredentials
+	}
+	return &Mac{AccessKey: keys.AccessKey, SecretKey: []byte(keys.SecretKey)}, nil
+}
+
+// ----------------------------------------------------------
+
+// 定期刷新的密钥提供者，缓存 Provider 返回的密钥，超过 Interval 后重新获取。
+// 刷新失败时继续使用上一次获取到的密钥，例如挂载的 Secret 正在更新时
+type RefreshableProvider struct {
+	Provider CredentialsProvider
+	Interval time.Duration
+
+	mu        sync.Mutex
+	mac       *Mac
+	refreshAt time.Time
+	now       func() time.Time // 为空时使用 time.Now，测试时替换
+}
+
+func NewRefreshableProvider(provider CredentialsProvider, interval time.Duration) *RefreshableProvider {
+
+	return &RefreshableProvider{Provider: provider, Interval: interval}
+}
+
+func (p *RefreshableProvider) Retrieve() (*Mac, error) {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if p.now != nil {
+		now = p.now()
+	}
+	if p.mac != nil && now.Before(p.refreshAt) {
+		return p.mac, nil
+	}
+	mac, err := p.Provider.Retrieve()
+	if err != nil {
+		if p.mac != nil {
+			p.refreshAt = now.Add(p.Interval)
+			return p.mac, nil
+		}
+		return nil, err
+	}
+	p.mac, p.refreshAt = mac, now.Add(p.Interval)
+	return mac, nil
+}
+
+// 下一次调用 Retrieve 时重新获取密钥
+func (p *RefreshableProvider) Expire() {
+
+	p.mu.Lock()
+	p.refreshAt = time.Time{}
+	p.mu.Unlock()
+}
+
+// ----------------------------------------------------------
@@ -0,0 +1,137 @@
+package qbox
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// stubProvider 按顺序返回预设的结果
+type stubProvider struct {
+	results []error // 为 nil 时返回 AccessKey 为 ak<调用次数> 的密钥
+	calls   int
+}
+
+func (p *stubProvider) Retrieve() (*Mac, error) {
+	err := p.results[p.calls]
+	p.calls++
+	if err != nil {
+		return nil, err
+	}
+	return NewMac("ak"+strconv.Itoa(p.calls), "sk"), nil
+}
+
+func TestRefreshableProvider(t *testing.T) {
+
+	errRead := errors.New("read failed")
+	stub := &stubProvider{results: []error{errRead, nil, nil, errRead, nil}}
+	now := time.Unix(1600000000, 0)
+	p := NewRefreshableProvider(stub, time.Minute)
+	p.now = func() time.Time { return now }
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		expire  bool
+		ak      string // 为空时期望返回错误
+		calls   int
+	}{
+		{name: "error without cached value", ak: "", calls: 1},
+		{name: "first value", ak: "ak2", calls: 2},
+		{name: "cached", advance: 30 * time.Second, ak: "ak2", calls: 2},
+		{name: "refreshed after interval", advance: 31 * time.Second, ak: "ak3", calls: 3},
+		{name: "fallback to last good value", advance: 2 * time.Minute, ak: "ak3", calls: 4},
+		{name: "fallback is cached for an interval", advance: 59 * time.Second, ak: "ak3", calls: 4},
+		{name: "expire", expire: true, ak: "ak5", calls: 5},
+	}
+	for _, s := range steps {
+		now = now.Add(s.advance)
+		if s.expire {
+			p.Expire()
+		}
+		mac, err := p.Retrieve()
+		switch {
+		case s.ak == "" && err != errRead:
+			t.Fatalf("%s: error %v, want %v", s.name, err, errRead)
+		case s.ak != "" && (err != nil || mac.AccessKey != s.ak):
+			t.Fatalf("%s: got %v, %v, want %s", s.name, mac, err, s.ak)
+		}
+		if stub.calls != s.calls {
+			t.Fatalf("%s: provider called %d times, want %d", s.name, stub.calls, s.calls)
+		}
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+
+	dir := t.TempDir()
+	cases := []struct {
+		name    string
+		content string // 为空时不创建文件
+		ak      string // 为空时期望返回错误
+		check   func(err error) bool
+	}{
+		{name: "valid", content: `{"ak":"file-ak","sk":"file-sk"}`, ak: "file-ak"},
+		{name: "missing file", check: os.IsNotExist},
+		{name: "broken json", content: `{"ak":`, check: func(err error) bool { return err != nil }},
+		{name: "missing sk", content: `{"ak":"file-ak"}`, check: func(err error) bool { return err == ErrNoCredentials }},
+	}
+	for _, c := range cases {
+		path := filepath.Join(dir, c.name)
+		if c.content != "" {
+			if err := ioutil.WriteFile(path, []byte(c.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		mac, err := NewFileProvider(path).Retrieve()
+		if c.ak != "" {
+			if err != nil || mac.AccessKey != c.ak || string(mac.SecretKey) != "file-sk" {
+				t.Errorf("%s: got %v, %v", c.name, mac, err)
+			}
+			continue
+		}
+		if !c.check(err) {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+	}
+}
+
+func TestEnvProvider(t *testing.T) {
+
+	env := map[string]string{DefaultAccessKeyEnv: "env-ak", DefaultSecretKeyEnv: "env-sk", "MY_AK": "my-ak", "MY_SK": "my-sk"}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	cases := []struct {
+		name   string
+		p      *EnvProvider
+		unset  string
+		ak, sk string // 为空时期望返回 ErrNoCredentials
+	}{
+		{name: "default names", p: &EnvProvider{}, ak: "env-ak", sk: "env-sk"},
+		{name: "custom names", p: NewEnvProvider("MY_AK", "MY_SK"), ak: "my-ak", sk: "my-sk"},
+		{name: "missing sk", p: NewEnvProvider("MY_AK", "MISSING_SK")},
+		{name: "unset", p: &EnvProvider{}, unset: DefaultSecretKeyEnv},
+	}
+	for _, c := range cases {
+		if c.unset != "" {
+			os.Unsetenv(c.unset)
+		}
+		mac, err := c.p.Retrieve()
+		if c.ak == "" {
+			if err != ErrNoCredentials {
+				t.Errorf("%s: error %v, want ErrNoCredentials", c.name, err)
+			}
+			continue
+		}
+		if err != nil || mac.AccessKey != c.ak || string(mac.SecretKey) != c.sk {
+			t.Errorf("%s: got %v, %v", c.name, mac, err)
+		}
+	}
+}
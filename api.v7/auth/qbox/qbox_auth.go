@@ -119,7 +119,9 @@ func SignWithData(mac *Mac, data []byte) string {
 
 type Transport struct {
 	mac       Mac
+	provider  CredentialsProvider
 	Transport http.RoundTripper
+	Scheme    SignScheme // 默认的签名方式，可以通过 WithSignScheme 为单个请求指定
 }
 
 func incBody(req *http.Request) bool {
@@ -143,7 +145,27 @@ func (t *Transport) NestedObject() interface{} {
 
 func (t *Transport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
 
-	token, err := t.mac.SignRequest(req, incBody(req))
+	mac := &t.mac
+	if t.provider != nil {
+		if mac, err = t.provider.Retrieve(); err != nil {
+			return
+		}
+	}
+	if signSchemeFromContext(req.Context(), t.Scheme) == SignQiniu {
+		if req.Header.Get(XQiniuDate) == "" {
+			req.Header.Set(XQiniuDate, time.Now().UTC().Format(XQiniuDateFormat))
+		}
+		if req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", defaultContentTypeV2)
+		}
+		token, err := mac.SignRequestV2(req)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Qiniu "+token)
+		return t.Transport.RoundTrip(req)
+	}
+	token, err := mac.SignRequest(req, incBody(req))
 	if err != nil {
 		return
 	}
@@ -163,10 +185,25 @@ func NewTransport(mac *Mac, transport http.RoundTripper) *Transport {
 	return t
 }
 
+// 创建每次签名前都从 provider 获取密钥的 Transport
+func NewTransportWithProvider(provider CredentialsProvider, transport http.RoundTripper) *Transport {
+
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Transport{provider: provider, Transport: transport}
+}
+
 func NewClient(mac *Mac, transport http.RoundTripper) *http.Client {
 
 	t := NewTransport(mac, transport)
 	return &http.Client{Transport: t, Timeout: 10 * time.Minute}
 }
 
+func NewClientWithProvider(provider CredentialsProvider, transport http.RoundTripper) *http.Client {
+
+	t := NewTransportWithProvider(provider, transport)
+	return &http.Client{Transport: t, Timeout: 10 * time.Minute}
+}
+
 // ---------------------------------------------------------------------------------------
@@ -0,0 +1,143 @@
+package qbox
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strings"
+
+	"github.com/qiniupd/qiniu-go-sdk/x/bytes.v7/seekable"
+)
+
+// ----------------------------------------------------------
+
+// 请求签名方式
+type SignScheme int
+
+const (
+	SignQBox  SignScheme = iota // Authorization: QBox <token>，只签名 path、query 和表单 body
+	SignQiniu                   // Authorization: Qiniu <token>，额外签名 method、host、Content-Type、X-Qiniu-* 头部和 JSON body
+)
+
+const xQiniuHeaderPrefix = "X-Qiniu-"
+
+// X-Qiniu-Date 头部记录请求的签名时间，会参与 Qiniu 签名，服务端可以据此拒绝过期的请求
+const (
+	XQiniuDate       = "X-Qiniu-Date"
+	XQiniuDateFormat = "20060102T150405Z"
+)
+
+type signSchemeKey struct{}
+
+// 为单个请求指定签名方式，优先于 Transport.Scheme
+func WithSignScheme(ctx context.Context, scheme SignScheme) context.Context {
+	return context.WithValue(ctx, signSchemeKey{}, scheme)
+}
+
+func signSchemeFromContext(ctx context.Context, def SignScheme) SignScheme {
+	if scheme, ok := ctx.Value(signSchemeKey{}).(SignScheme); ok {
+		return scheme
+	}
+	return def
+}
+
+// ----------------------------------------------------------
+
+// 没有 Content-Type 头部时按表单签名
+const defaultContentTypeV2 = "application/x-www-form-urlencoded"
+
+func incBodyV2(req *http.Request, contentType string) bool {
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return false
+	}
+	switch contentType {
+	case "application/x-www-form-urlencoded", "application/json":
+		return true
+	}
+	return false
+}
+
+// 使用 Qiniu 签名算法对请求签名，返回的 token 需要以 "Qiniu " 为前缀放入 Authorization 头部。
+// 不修改 req 的头部，没有 Content-Type 时按 application/x-www-form-urlencoded 签名，
+// 发送请求时需要自行设置相同的 Content-Type
+func (mac *Mac) SignRequestV2(req *http.Request) (token string, err error) {
+
+	h := hmac.New(sha1.New, mac.SecretKey)
+
+	u := req.URL
+	data := req.Method + " " + u.Path
+	if u.RawQuery != "" {
+		data += "?" + u.RawQuery
+	}
+	host := req.Host
+	if host == "" {
+		host = u.Host
+	}
+	data += "\nHost: " + host
+
+	contentType := req.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = defaultContentTypeV2
+	}
+	data += "\nContent-Type: " + contentType
+
+	// 同名头部的多个值按出现顺序用 ',' 连接
+	rawKeys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		rawKeys = append(rawKeys, k)
+	}
+	sort.Strings(rawKeys)
+	values := make(map[string][]string)
+	for _, raw := range rawKeys {
+		k := textproto.CanonicalMIMEHeaderKey(raw)
+		if len(k) > len(xQiniuHeaderPrefix) && strings.HasPrefix(k, xQiniuHeaderPrefix) {
+			values[k] = append(values[k], req.Header[raw]...)
+		}
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		data += "\n" + k + ": " + strings.Join(values[k], ",")
+	}
+	io.WriteString(h, data+"\n\n")
+
+	if incBodyV2(req, contentType) {
+		s2, err2 := seekable.New(req)
+		if err2 != nil {
+			return "", err2
+		}
+		h.Write(s2.Bytes())
+	}
+
+	sign := base64.URLEncoding.EncodeToString(h.Sum(nil))
+	token = mac.AccessKey + ":" + sign
+	return
+}
+
+// 校验使用 Qiniu 签名算法的回调请求
+func (mac *Mac) VerifyCallbackV2(req *http.Request) (bool, error) {
+
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Qiniu ") {
+		return false, nil
+	}
+
+	token, err := mac.SignRequestV2(req)
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare([]byte(auth), []byte("Qiniu "+token)) == 1, nil
+}
+
+// ----------------------------------------------------------
@@ -0,0 +1,90 @@
+package qbox
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 期望值按文档中的 Qiniu 签名规则独立计算：
+// HMAC-SHA1(sk, "<Method> <Path>[?<Query>]\nHost: <Host>\nContent-Type: <ContentType>[\n<X-Qiniu-*>: <Value>]...\n\n[<Body>]")
+func TestSignRequestV2(t *testing.T) {
+
+	mac := NewMac("MY_ACCESS_KEY", "MY_SECRET_KEY")
+	cases := []struct {
+		method, url, contentType, body string
+		header                         http.Header
+		token                          string
+	}{
+		{
+			method:      "POST",
+			url:         "http://rs.qiniu.com/move/bmV3ZG9jczpmaW5kX21hbi50eHQ=/bmV3ZG9jczpmaW5kLm1hbi50eHQ=",
+			contentType: "application/x-www-form-urlencoded",
+			token:       "MY_ACCESS_KEY:RhpbGfadBwhhyYrc9n7_NruxZ-o=",
+		},
+		{
+			method:      "GET",
+			url:         "http://uc.qbox.me/v2/query?ak=MY_ACCESS_KEY&bucket=test",
+			contentType: "application/json",
+			body:        `{"a":1}`,
+			header:      http.Header{"X-Qiniu-Bbb": {"1", "2"}, "X-Qiniu-Aaa": {"x"}, "X-Qiniu-": {"ignored"}, "X-Other": {"ignored"}},
+			token:       "MY_ACCESS_KEY:5ns7ZaXwWYKfyLgPOPfrBKlBDFY=",
+		},
+		{
+			method: "POST",
+			url:    "http://example.com/callback",
+			body:   "name=test&size=1",
+			token:  "MY_ACCESS_KEY:W8vXjA7hYz5AW1iG3HJ_H0O9J5o=",
+		},
+	}
+	for _, c := range cases {
+		req, err := http.NewRequest(c.method, c.url, strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.body == "" {
+			req.Body = nil
+		}
+		for k, v := range c.header {
+			req.Header[k] = v
+		}
+		if c.contentType != "" {
+			req.Header.Set("Content-Type", c.contentType)
+		}
+		token, err := mac.SignRequestV2(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != c.token {
+			t.Fatalf("%s %s: unexpected token %s, expected %s", c.method, c.url, token, c.token)
+		}
+		if req.Header.Get("Content-Type") != c.contentType {
+			t.Fatal("request header should not be modified:", req.Header)
+		}
+	}
+}
+
+// Transport 发出的请求带有签名时使用的 Content-Type，服务端可以用同样的规则校验
+func TestTransportSignQiniu(t *testing.T) {
+
+	mac := NewMac("MY_ACCESS_KEY", "MY_SECRET_KEY")
+	var contentType string
+	var ok bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		contentType = req.Header.Get("Content-Type")
+		ok, _ = mac.VerifyCallbackV2(req)
+	}))
+	defer srv.Close()
+
+	tr := NewTransport(mac, nil)
+	tr.Scheme = SignQiniu
+	resp, err := (&http.Client{Transport: tr}).Post(srv.URL+"/callback", "", strings.NewReader("name=test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if contentType != "application/x-www-form-urlencoded" || !ok {
+		t.Fatalf("unexpected request: Content-Type %q, verified %v", contentType, ok)
+	}
+}
@@ -256,6 +256,8 @@ type FileType uint32
 const (
 	TypeNormal = iota
 	TypeLine
+	TypeArchive
+	TypeDeepArchive
 )
 
 func URIChangeType(bucket, key string, Type FileType) string {
@@ -0,0 +1,161 @@
+package kodo
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// ----------------------------------------------------------
+
+// 生命周期规则，天数为 0 时表示不执行对应操作
+type LifecycleRule struct {
+	Name               string `json:"name"`   // 规则名称，在存储空间内唯一
+	Prefix             string `json:"prefix"` // 匹配的对象 key 前缀，为空时匹配所有对象
+	DeleteAfterDays    int    `json:"delete_after_days"`
+	ToLineAfterDays    int    `json:"to_line_after_days"`    // 上传后指定天数转为低频存储
+	ToArchiveAfterDays int    `json:"to_archive_after_days"` // 上传后指定天数转为归档存储
+	Ctime              string `json:"ctime,omitempty"`
+}
+
+func (rule *LifecycleRule) form(bucket string) map[string][]string {
+	return map[string][]string{
+		"bucket":                {bucket},
+		"name":                  {rule.Name},
+		"prefix":                {rule.Prefix},
+		"delete_after_days":     {strconv.Itoa(rule.DeleteAfterDays)},
+		"to_line_after_days":    {strconv.Itoa(rule.ToLineAfterDays)},
+		"to_archive_after_days": {strconv.Itoa(rule.ToArchiveAfterDays)},
+	}
+}
+
+// 列举存储空间的生命周期规则。
+func (p *Client) LifecycleRules(ctx context.Context, bucket string) (rules []LifecycleRule, err error) {
+	err = p.Call(ctx, &rules, "GET", p.UCHost+"/rules/get?bucket="+url.QueryEscape(bucket))
+	return
+}
+
+// 添加生命周期规则，同名规则已存在时失败。
+func (p *Client) AddLifecycleRule(ctx context.Context, bucket string, rule *LifecycleRule) (err error) {
+	return p.CallWithForm(ctx, nil, "POST", p.UCHost+"/rules/add", rule.form(bucket))
+}
+
+// 根据 rule.Name 更新生命周期规则。
+func (p *Client) UpdateLifecycleRule(ctx context.Context, bucket string, rule *LifecycleRule) (err error) {
+	return p.CallWithForm(ctx, nil, "POST", p.UCHost+"/rules/update", rule.form(bucket))
+}
+
+// 删除指定名称的生命周期规则。
+func (p *Client) DeleteLifecycleRule(ctx context.Context, bucket, name string) (err error) {
+	return p.CallWithForm(ctx, nil, "POST", p.UCHost+"/rules/delete", map[string][]string{
+		"bucket": {bucket},
+		"name":   {name},
+	})
+}
+
+// ----------------------------------------------------------
+
+// 触发事件通知的对象操作
+type EventType string
+
+const (
+	EventPut     EventType = "put"
+	EventMkfile  EventType = "mkfile"
+	EventDelete  EventType = "delete"
+	EventCopy    EventType = "copy"
+	EventMove    EventType = "move"
+	EventAppend  EventType = "append"
+	EventDisable EventType = "disable"
+	EventEnable  EventType = "enable"
+)
+
+// 事件通知规则
+type EventRule struct {
+	Name         string      `json:"name"` // 规则名称，在存储空间内唯一
+	Prefix       string      `json:"prefix"`
+	Suffix       string      `json:"suffix"`
+	Events       []EventType `json:"event"`
+	CallbackUrls []string    `json:"callback_urls"` // 多个地址时依次重试
+	AccessKey    string      `json:"access_key"`    // 不为空时使用对应的密钥对通知请求签名
+	Host         string      `json:"host"`          // 通知请求的 Host 头部
+}
+
+func (rule *EventRule) form(bucket string) map[string][]string {
+	form := map[string][]string{
+		"bucket":      {bucket},
+		"name":        {rule.Name},
+		"prefix":      {rule.Prefix},
+		"suffix":      {rule.Suffix},
+		"callbackURL": rule.CallbackUrls,
+	}
+	for _, e := range rule.Events {
+		form["event"] = append(form["event"], string(e))
+	}
+	if rule.AccessKey != "" {
+		form["access_key"] = []string{rule.AccessKey}
+	}
+	if rule.Host != "" {
+		form["host"] = []string{rule.Host}
+	}
+	return form
+}
+
+// 列举存储空间的事件通知规则。
+func (p *Client) EventRules(ctx context.Context, bucket string) (rules []EventRule, err error) {
+	err = p.Call(ctx, &rules, "GET", p.UCHost+"/events/get?bucket="+url.QueryEscape(bucket))
+	return
+}
+
+// 添加事件通知规则，同名规则已存在时失败。
+func (p *Client) AddEventRule(ctx context.Context, bucket string, rule *EventRule) (err error) {
+	return p.CallWithForm(ctx, nil, "POST", p.UCHost+"/events/add", rule.form(bucket))
+}
+
+// 根据 rule.Name 更新事件通知规则。
+func (p *Client) UpdateEventRule(ctx context.Context, bucket string, rule *EventRule) (err error) {
+	return p.CallWithForm(ctx, nil, "POST", p.UCHost+"/events/update", rule.form(bucket))
+}
+
+// 删除指定名称的事件通知规则。
+func (p *Client) DeleteEventRule(ctx context.Context, bucket, name string) (err error) {
+	return p.CallWithForm(ctx, nil, "POST", p.UCHost+"/events/delete", map[string][]string{
+		"bucket": {bucket},
+		"name":   {name},
+	})
+}
+
+// ----------------------------------------------------------
+
+// 跨域规则
+type CorsRule struct {
+	AllowedOrigins []string `json:"allowed_origin"`
+	AllowedMethods []string `json:"allowed_method"`
+	AllowedHeaders []string `json:"allowed_header,omitempty"`
+	ExposedHeaders []string `json:"exposed_header,omitempty"`
+	MaxAge         int64    `json:"max_age,omitempty"` // 预检请求结果的缓存时间，单位秒
+}
+
+// 获取存储空间的跨域规则。
+func (p *Client) CorsRules(ctx context.Context, bucket string) (rules []CorsRule, err error) {
+	err = p.Call(ctx, &rules, "GET", p.UCHost+"/corsRules/get/"+url.PathEscape(bucket))
+	return
+}
+
+// 设置存储空间的跨域规则，会覆盖已有的全部规则，rules 为空时删除所有跨域规则。
+func (p *Client) SetCorsRules(ctx context.Context, bucket string, rules []CorsRule) (err error) {
+	if rules == nil {
+		rules = []CorsRule{}
+	}
+	return p.CallWithJson(ctx, nil, "POST", p.UCHost+"/corsRules/set/"+url.PathEscape(bucket), rules)
+}
+
+// 在已有的跨域规则之后追加一条规则。
+func (p *Client) AddCorsRule(ctx context.Context, bucket string, rule CorsRule) (err error) {
+	rules, err := p.CorsRules(ctx, bucket)
+	if err != nil {
+		return
+	}
+	return p.SetCorsRules(ctx, bucket, append(rules, rule))
+}
+
+// ----------------------------------------------------------
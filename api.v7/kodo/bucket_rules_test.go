@@ -0,0 +1,75 @@
+package kodo
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestBucketRules(t *testing.T) {
+
+	var corsBody []CorsRule
+	forms := make(map[string]url.Values)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/rules/get":
+			w.Write([]byte(`[{"name":"cold","prefix":"sealed/","to_archive_after_days":30}]`))
+		case "/events/get":
+			w.Write([]byte(`[{"name":"notify","suffix":".car","event":["put","delete"],"callback_urls":["http://a","http://b"]}]`))
+		case "/corsRules/get/test":
+			w.Write([]byte(`[{"allowed_origin":["*"],"allowed_method":["GET"]}]`))
+		case "/corsRules/set/test":
+			b, _ := ioutil.ReadAll(req.Body)
+			json.Unmarshal(b, &corsBody)
+			w.Write([]byte(`{}`))
+		default:
+			req.ParseForm()
+			forms[req.URL.Path] = req.PostForm
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := NewWithoutZone(&Config{AccessKey: "ak", SecretKey: "sk", UCHost: srv.URL})
+	ctx := context.Background()
+
+	rules, err := c.LifecycleRules(ctx, "test")
+	if err != nil || len(rules) != 1 || rules[0].Prefix != "sealed/" || rules[0].ToArchiveAfterDays != 30 {
+		t.Fatal("LifecycleRules:", rules, err)
+	}
+	if err = c.AddLifecycleRule(ctx, "test", &LifecycleRule{Name: "tmp", Prefix: "tmp/", DeleteAfterDays: 7}); err != nil {
+		t.Fatal(err)
+	}
+	if f := forms["/rules/add"]; f.Get("bucket") != "test" || f.Get("name") != "tmp" || f.Get("delete_after_days") != "7" {
+		t.Fatal("unexpected lifecycle form:", f)
+	}
+
+	events, err := c.EventRules(ctx, "test")
+	if err != nil || len(events) != 1 || len(events[0].Events) != 2 || events[0].CallbackUrls[1] != "http://b" {
+		t.Fatal("EventRules:", events, err)
+	}
+	err = c.UpdateEventRule(ctx, "test", &EventRule{
+		Name: "notify", Suffix: ".car", Events: []EventType{EventPut, EventDelete}, CallbackUrls: []string{"http://a"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := forms["/events/update"]; len(f["event"]) != 2 || f.Get("callbackURL") != "http://a" || f.Get("suffix") != ".car" {
+		t.Fatal("unexpected event form:", f)
+	}
+	if err = c.DeleteEventRule(ctx, "test", "notify"); err != nil || forms["/events/delete"].Get("name") != "notify" {
+		t.Fatal("DeleteEventRule:", err)
+	}
+
+	if err = c.AddCorsRule(ctx, "test", CorsRule{AllowedOrigins: []string{"https://example.com"}, AllowedMethods: []string{"PUT"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(corsBody) != 2 || corsBody[1].AllowedOrigins[0] != "https://example.com" {
+		t.Fatal("AddCorsRule should append to existing rules:", corsBody)
+	}
+}
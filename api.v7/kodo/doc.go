@@ -45,7 +45,7 @@
 
 但是对于私有空间，事情要复杂一些，访问上面的 baseUrl 会被拒绝。我们需要多做一步：
 
-	privateUrl := c.MakePrivateUrl(baseUrl, nil) // 用默认的下载策略去生成私有下载的 url
+	privateUrl := c.MakePrivateUrl(baseUrl, nil) // 用默认的下载策略去生成私有下载的 url，获取密钥失败时为空字符串
 	resp, err := http.Get(privateUrl)
 	...
 */
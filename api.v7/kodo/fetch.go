@@ -0,0 +1,101 @@
+package kodo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/auth/qbox"
+	"github.com/qiniupd/qiniu-go-sdk/x/rpc.v7"
+)
+
+// ----------------------------------------------------------
+
+// 异步抓取任务参数
+type AsyncFetchArgs struct {
+	Url              string   `json:"url"`            // 要抓取的资源地址，多个地址用 ';' 分隔，依次尝试
+	Host             string   `json:"host,omitempty"` // 抓取时使用的 Host 头部
+	Key              string   `json:"key,omitempty"`  // 为空时使用资源内容的 etag 作为 key
+	Md5              string   `json:"md5,omitempty"`  // 资源内容的 md5，不匹配时抓取失败
+	Etag             string   `json:"etag,omitempty"`
+	CallbackUrl      string   `json:"callbackurl,omitempty"`
+	CallbackBody     string   `json:"callbackbody,omitempty"`
+	CallbackBodyType string   `json:"callbackbodytype,omitempty"`
+	CallbackHost     string   `json:"callbackhost,omitempty"`
+	FileType         FileType `json:"file_type,omitempty"`
+	IgnoreSameKey    bool     `json:"ignore_same_key,omitempty"` // 为 true 时同名对象已存在则不抓取
+}
+
+// 异步抓取任务状态
+type AsyncFetchRet struct {
+	Id   string `json:"id"`
+	Wait int    `json:"wait"` // 排在该任务之前的任务数，0 表示正在抓取，-1 表示已经至少处理过一次
+}
+
+// 任务是否已经被处理过，处理结果通过回调通知
+func (ret *AsyncFetchRet) Done() bool {
+	return ret.Wait == -1
+}
+
+// 提交异步抓取任务，立即返回任务 id，抓取结果通过 args.CallbackUrl 回调通知。
+//
+// ctx  是请求的上下文。
+// args 是抓取任务参数。
+func (p Bucket) AsyncFetch(ctx context.Context, args *AsyncFetchArgs) (ret AsyncFetchRet, err error) {
+	body := struct {
+		*AsyncFetchArgs
+		Bucket string `json:"bucket"`
+	}{args, p.Name}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return
+	}
+	err = p.Conn.callQiniu(ctx, &ret, "POST", p.Conn.APIHost+"/sisyphus/fetch", "application/json", b)
+	return
+}
+
+// 查询异步抓取任务的状态。
+func (p Bucket) AsyncFetchStatus(ctx context.Context, id string) (ret AsyncFetchRet, err error) {
+	err = p.Conn.callQiniu(ctx, &ret, "GET", p.Conn.APIHost+"/sisyphus/fetch?id="+url.QueryEscape(id), "", nil)
+	return
+}
+
+// callQiniu 使用 Qiniu 签名发送请求，/sisyphus/fetch 等接口不接受 QBox 签名
+func (p *Client) callQiniu(ctx context.Context, ret interface{}, method, url1, bodyType string, body []byte) (err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := rpc.NewRequest(method, url1, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	if body == nil {
+		req.Body = nil
+	}
+	if bodyType != "" {
+		req.Header.Set("Content-Type", bodyType)
+	}
+	req.ContentLength = int64(len(body))
+	// rpc.Client 不会把 ctx 设置到请求上，签名方式需要通过请求的 context 传给 qbox.Transport
+	req = req.WithContext(qbox.WithSignScheme(ctx, qbox.SignQiniu))
+	resp, err := p.Do(ctx, req)
+	if err != nil {
+		return
+	}
+	return rpc.CallRet(ctx, ret, resp)
+}
+
+// 从镜像源预取文件，镜像源上的文件更新后可以用来刷新存储空间中的副本，只适用于设置了镜像源的存储空间。
+//
+// ctx 是请求的上下文。
+// key 是要预取的文件的访问路径。
+func (p Bucket) Prefetch(ctx context.Context, key string) (err error) {
+	return p.Conn.Call(ctx, nil, "POST", p.Conn.IoHost+URIPrefetch(p.Name, key))
+}
+
+func URIPrefetch(bucket, key string) string {
+	return "/prefetch/" + encodeURI(bucket+":"+key)
+}
+
+// ----------------------------------------------------------
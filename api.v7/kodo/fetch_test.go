@@ -0,0 +1,65 @@
+package kodo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/auth/qbox"
+)
+
+func TestAsyncFetch(t *testing.T) {
+
+	var submitted map[string]interface{}
+	var prefetched string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// /sisyphus/fetch 只接受 Qiniu 签名，其他接口使用 QBox 签名
+		scheme := "QBox ak:"
+		if req.URL.Path == "/sisyphus/fetch" {
+			scheme = "Qiniu ak:"
+		}
+		if !strings.HasPrefix(req.Header.Get("Authorization"), scheme) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if scheme == "Qiniu ak:" {
+			if ok, err := qbox.NewMac("ak", "sk").VerifyCallbackV2(req); err != nil || !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case req.URL.Path == "/sisyphus/fetch" && req.Method == "POST":
+			json.NewDecoder(req.Body).Decode(&submitted)
+			w.Write([]byte(`{"id":"job-1","wait":3}`))
+		case req.URL.Path == "/sisyphus/fetch":
+			w.Write([]byte(`{"id":"` + req.URL.Query().Get("id") + `","wait":-1}`))
+		default:
+			prefetched = req.URL.Path
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := NewWithoutZone(&Config{AccessKey: "ak", SecretKey: "sk", APIHost: srv.URL, IoHost: srv.URL})
+	b := c.Bucket("dataset")
+	ctx := context.Background()
+
+	ret, err := b.AsyncFetch(ctx, &AsyncFetchArgs{Url: "http://example.com/a.car", Key: "a.car", CallbackUrl: "http://example.com/cb", CallbackBody: "key=$(key)"})
+	if err != nil || ret.Id != "job-1" || ret.Done() {
+		t.Fatal("AsyncFetch:", ret, err)
+	}
+	if submitted["bucket"] != "dataset" || submitted["url"] != "http://example.com/a.car" || submitted["callbackurl"] != "http://example.com/cb" {
+		t.Fatal("unexpected fetch body:", submitted)
+	}
+	if ret, err = b.AsyncFetchStatus(ctx, "job-1"); err != nil || ret.Id != "job-1" || !ret.Done() {
+		t.Fatal("AsyncFetchStatus:", ret, err)
+	}
+	if err = b.Prefetch(ctx, "a.car"); err != nil || prefetched != URIPrefetch("dataset", "a.car") {
+		t.Fatal("Prefetch:", prefetched, err)
+	}
+}
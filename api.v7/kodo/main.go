@@ -12,13 +12,15 @@ import (
 
 type zoneConfig struct {
 	IoHost  string
+	ApiHost string
 	UpHosts []string
 }
 
 var zones = []zoneConfig{
 	// z0 华东机房:
 	{
-		IoHost: "http://iovip.qbox.me",
+		IoHost:  "http://iovip.qbox.me",
+		ApiHost: "http://api.qiniu.com",
 		UpHosts: []string{
 			"http://up.qiniu.com",
 			"http://upload.qiniu.com",
@@ -27,7 +29,8 @@ var zones = []zoneConfig{
 	},
 	// z1 华北机房:
 	{
-		IoHost: "http://iovip-z1.qbox.me",
+		IoHost:  "http://iovip-z1.qbox.me",
+		ApiHost: "http://api-z1.qiniu.com",
 		UpHosts: []string{
 			"http://up-z1.qiniu.com",
 			"http://upload-z1.qiniu.com",
@@ -36,7 +39,8 @@ var zones = []zoneConfig{
 	},
 	// z2 华南机房:
 	{
-		IoHost: "http://iovip-z2.qbox.me",
+		IoHost:  "http://iovip-z2.qbox.me",
+		ApiHost: "http://api-z2.qiniu.com",
 		UpHosts: []string{
 			"http://up-z2.qiniu.com",
 			"http://upload-z2.qiniu.com",
@@ -44,7 +48,8 @@ var zones = []zoneConfig{
 	},
 	// na0 北美机房:
 	{
-		IoHost: "http://iovip-na0.qbox.me",
+		IoHost:  "http://iovip-na0.qbox.me",
+		ApiHost: "http://api-na0.qiniu.com",
 		UpHosts: []string{
 			"http://up-na0.qiniu.com",
 			"http://upload-na0.qiniu.com",
@@ -55,6 +60,8 @@ var zones = []zoneConfig{
 const (
 	defaultRsHost  = "http://rs.qbox.me"
 	defaultRsfHost = "http://rsf.qbox.me"
+	defaultUcHost  = "http://uc.qbox.me"
+	defaultApiHost = "http://api.qiniu.com"
 )
 
 // ----------------------------------------------------------
@@ -64,11 +71,14 @@ type Config struct {
 	SecretKey string
 	RSHost    string
 	RSFHost   string
-	APIHost   string
+	APIHost   string // 异步抓取、持久化处理和域名服务，默认为 zone 对应的 api 域名，NewWithoutZone 时为 http://api.qiniu.com
+	UCHost    string // 存储空间管理服务，默认为 http://uc.qbox.me
 	Scheme    string
 	IoHost    string
 	UpHosts   []string
 	Transport http.RoundTripper
+
+	Credentials qbox.CredentialsProvider // 不为空时忽略 AccessKey 和 SecretKey，每次签名前从中获取密钥
 }
 
 // ----------------------------------------------------------
@@ -87,7 +97,11 @@ func New(zone int, cfg *Config) (p *Client) {
 	}
 
 	p.mac = qbox.NewMac(p.AccessKey, p.SecretKey)
-	p.Client = rpc.Client{qbox.NewClient(p.mac, p.Transport)}
+	client := qbox.NewClient(p.mac, p.Transport)
+	if p.Credentials != nil {
+		client = qbox.NewClientWithProvider(p.Credentials, p.Transport)
+	}
+	p.Client = rpc.Client{client}
 
 	if p.RSHost == "" {
 		p.RSHost = defaultRsHost
@@ -95,11 +109,17 @@ func New(zone int, cfg *Config) (p *Client) {
 	if p.RSFHost == "" {
 		p.RSFHost = defaultRsfHost
 	}
+	if p.UCHost == "" {
+		p.UCHost = defaultUcHost
+	}
 	if p.Scheme != "https" {
 		p.Scheme = "http"
 	}
 
 	if zone < 0 || zone >= len(zones) {
+		if p.APIHost == "" {
+			p.APIHost = defaultApiHost
+		}
 		return
 	}
 	if len(p.UpHosts) == 0 {
@@ -108,6 +128,9 @@ func New(zone int, cfg *Config) (p *Client) {
 	if p.IoHost == "" {
 		p.IoHost = zones[zone].IoHost
 	}
+	if p.APIHost == "" {
+		p.APIHost = zones[zone].ApiHost
+	}
 	return
 }
 
@@ -149,3 +149,24 @@ func TestEntry(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestZoneApiHost(t *testing.T) {
+
+	cases := []struct {
+		zone int
+		cfg  Config
+		want string
+	}{
+		{zone: 0, want: "http://api.qiniu.com"},
+		{zone: 1, want: "http://api-z1.qiniu.com"},
+		{zone: 2, want: "http://api-z2.qiniu.com"},
+		{zone: 3, want: "http://api-na0.qiniu.com"},
+		{zone: -1, want: "http://api.qiniu.com"},
+		{zone: 1, cfg: Config{APIHost: "http://api.example.com"}, want: "http://api.example.com"},
+	}
+	for _, c := range cases {
+		if got := New(c.zone, &c.cfg).APIHost; got != c.want {
+			t.Errorf("zone %d: APIHost %q, want %q", c.zone, got, c.want)
+		}
+	}
+}
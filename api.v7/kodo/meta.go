@@ -0,0 +1,156 @@
+package kodo
+
+import (
+	"context"
+	"sort"
+	"strconv"
+)
+
+// ----------------------------------------------------------
+
+// 对象状态，禁用的对象无法下载
+const (
+	StatusEnabled  = 0
+	StatusDisabled = 1
+)
+
+// 归档存储对象的解冻状态，对应 Entry.RestoreStatus
+const (
+	RestoreStatusFrozen    = 0
+	RestoreStatusRestoring = 1
+	RestoreStatusRestored  = 2
+)
+
+// 文件的详细属性
+type EntryDetail struct {
+	Entry
+	Type          FileType          `json:"type"`
+	Status        int               `json:"status"` // StatusEnabled 或 StatusDisabled
+	Md5           string            `json:"md5,omitempty"`
+	XQnMeta       map[string]string `json:"x-qn-meta,omitempty"`     // 自定义元信息，key 不含 x-qn-meta- 前缀
+	RestoreStatus int               `json:"restoreStatus,omitempty"` // 归档存储对象的解冻状态
+	Expiration    int64             `json:"expiration,omitempty"`    // 设置了 DeleteAfterDays 时为自动删除的时间
+}
+
+// 取文件的详细属性，包括自定义元信息、存储类型、状态、md5 和解冻状态。
+//
+// ctx 是请求的上下文。
+// key 是要访问的文件的访问路径。
+func (p Bucket) StatDetail(ctx context.Context, key string) (entry EntryDetail, err error) {
+	err = p.Conn.Call(ctx, &entry, "POST", p.Conn.RSHost+URIStat(p.Name, key))
+	return
+}
+
+// 设置文件的自定义元信息，meta 的 key 不需要 x-qn-meta- 前缀。
+// 设置后会覆盖文件已有的全部自定义元信息
+//
+// ctx  是请求的上下文。
+// key  是要修改的文件的访问路径。
+// meta 是要设置的元信息。
+func (p Bucket) SetMeta(ctx context.Context, key string, meta map[string]string) (err error) {
+	return p.Conn.Call(ctx, nil, "POST", p.Conn.RSHost+URIChangeMeta(p.Name, key, "", meta))
+}
+
+// 修改文件的存储类型。
+//
+// ctx      是请求的上下文。
+// key      是要修改的文件的访问路径。
+// fileType 是新的存储类型，可以是 TypeNormal、TypeLine、TypeArchive 或 TypeDeepArchive。
+func (p Bucket) ChangeType(ctx context.Context, key string, fileType FileType) (err error) {
+	return p.Conn.Call(ctx, nil, "POST", p.Conn.RSHost+URIChangeType(p.Name, key, fileType))
+}
+
+// 设置文件在指定天数后自动删除，days 为 0 时取消自动删除。
+func (p Bucket) DeleteAfterDays(ctx context.Context, key string, days int) (err error) {
+	return p.Conn.Call(ctx, nil, "POST", p.Conn.RSHost+URIDeleteAfterDays(p.Name, key, days))
+}
+
+// 修改文件状态，status 为 StatusEnabled 或 StatusDisabled。
+func (p Bucket) ChangeStatus(ctx context.Context, key string, status int) (err error) {
+	return p.Conn.Call(ctx, nil, "POST", p.Conn.RSHost+URIChangeStatus(p.Name, key, status))
+}
+
+// ----------------------------------------------------------
+
+type BatchStatDetailItemRet struct {
+	Data  EntryDetail `json:"data"`
+	Error string      `json:"error"`
+	Code  int         `json:"code"`
+}
+
+func (p Bucket) BatchStatDetail(ctx context.Context, keys ...string) (ret []BatchStatDetailItemRet, err error) {
+
+	b := make([]string, len(keys))
+	for i, key := range keys {
+		b[i] = URIStat(p.Name, key)
+	}
+	err = p.Conn.Batch(ctx, &ret, b)
+	return
+}
+
+func (p Bucket) batchKeys(ctx context.Context, keys []string, uri func(key string) string) (ret []BatchItemRet, err error) {
+
+	b := make([]string, len(keys))
+	for i, key := range keys {
+		b[i] = uri(key)
+	}
+	err = p.Conn.Batch(ctx, &ret, b)
+	return
+}
+
+func (p Bucket) BatchSetMeta(ctx context.Context, meta map[string]string, keys ...string) (ret []BatchItemRet, err error) {
+
+	return p.batchKeys(ctx, keys, func(key string) string {
+		return URIChangeMeta(p.Name, key, "", meta)
+	})
+}
+
+func (p Bucket) BatchChangeType(ctx context.Context, fileType FileType, keys ...string) (ret []BatchItemRet, err error) {
+
+	return p.batchKeys(ctx, keys, func(key string) string {
+		return URIChangeType(p.Name, key, fileType)
+	})
+}
+
+func (p Bucket) BatchDeleteAfterDays(ctx context.Context, days int, keys ...string) (ret []BatchItemRet, err error) {
+
+	return p.batchKeys(ctx, keys, func(key string) string {
+		return URIDeleteAfterDays(p.Name, key, days)
+	})
+}
+
+func (p Bucket) BatchChangeStatus(ctx context.Context, status int, keys ...string) (ret []BatchItemRet, err error) {
+
+	return p.batchKeys(ctx, keys, func(key string) string {
+		return URIChangeStatus(p.Name, key, status)
+	})
+}
+
+// ----------------------------------------------------------
+
+// mime 为空时只修改自定义元信息，meta 的 key 不需要 x-qn-meta- 前缀
+func URIChangeMeta(bucket, key, mime string, meta map[string]string) string {
+	uri := "/chgm/" + encodeURI(bucket+":"+key)
+	if mime != "" {
+		uri += "/mime/" + encodeURI(mime)
+	}
+	names := make([]string, 0, len(meta))
+	for k := range meta {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		uri += "/x-qn-meta-" + k + "/" + encodeURI(meta[k])
+	}
+	return uri
+}
+
+func URIDeleteAfterDays(bucket, key string, days int) string {
+	return "/deleteAfterDays/" + encodeURI(bucket+":"+key) + "/" + strconv.Itoa(days)
+}
+
+func URIChangeStatus(bucket, key string, status int) string {
+	return "/chstatus/" + encodeURI(bucket+":"+key) + "/status/" + strconv.Itoa(status)
+}
+
+// ----------------------------------------------------------
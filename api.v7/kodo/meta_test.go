@@ -0,0 +1,59 @@
+package kodo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetaURIs(t *testing.T) {
+
+	entry := encodeURI("bucket:a/b.txt")
+	cases := [][2]string{
+		{URIChangeType("bucket", "a/b.txt", TypeArchive), "/chtype/" + entry + "/type/2"},
+		{URIChangeMeta("bucket", "a/b.txt", "", map[string]string{"b": "2", "a": "1"}),
+			"/chgm/" + entry + "/x-qn-meta-a/" + encodeURI("1") + "/x-qn-meta-b/" + encodeURI("2")},
+		{URIChangeMeta("bucket", "a/b.txt", "text/plain", nil), "/chgm/" + entry + "/mime/" + encodeURI("text/plain")},
+		{URIDeleteAfterDays("bucket", "a/b.txt", 7), "/deleteAfterDays/" + entry + "/7"},
+		{URIChangeStatus("bucket", "a/b.txt", StatusDisabled), "/chstatus/" + entry + "/status/1"},
+		{URIRestoreAr("bucket", "a/b.txt", 3), "/restoreAr/" + entry + "/freezeAfterDays/3"},
+	}
+	for _, c := range cases {
+		if c[0] != c[1] {
+			t.Fatalf("unexpected uri %s, expected %s", c[0], c[1])
+		}
+	}
+}
+
+func TestStatDetail(t *testing.T) {
+
+	var ops []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/batch":
+			req.ParseForm()
+			ops = req.PostForm["op"]
+			w.Write([]byte(`[{"code":200},{"code":612,"error":"no such file or directory"}]`))
+		default:
+			w.Write([]byte(`{"fsize":3,"type":2,"status":1,"md5":"abc","x-qn-meta":{"owner":"f01234"},"restoreStatus":1}`))
+		}
+	}))
+	defer srv.Close()
+
+	b := NewWithoutZone(&Config{AccessKey: "ak", SecretKey: "sk", RSHost: srv.URL}).Bucket("bucket")
+	entry, err := b.StatDetail(context.Background(), "a.car")
+	if err != nil || entry.Fsize != 3 || entry.Type != TypeArchive || entry.Status != StatusDisabled ||
+		entry.XQnMeta["owner"] != "f01234" || entry.RestoreStatus != RestoreStatusRestoring {
+		t.Fatal("StatDetail:", entry, err)
+	}
+
+	ret, err := b.BatchRestoreAr(context.Background(), 1, "a.car", "b.car")
+	if err != nil || len(ret) != 2 || ret[1].Code != 612 {
+		t.Fatal("BatchRestoreAr:", ret, err)
+	}
+	if len(ops) != 2 || ops[0] != URIRestoreAr("bucket", "a.car", 1) || ops[1] != URIRestoreAr("bucket", "b.car", 1) {
+		t.Fatal("unexpected batch ops:", ops)
+	}
+}
@@ -0,0 +1,117 @@
+package kodo
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ----------------------------------------------------------
+
+// 持久化处理任务及其中每条命令的状态
+const (
+	PrefopSucceeded    = 0
+	PrefopWaiting      = 1
+	PrefopProcessing   = 2
+	PrefopFailed       = 3
+	PrefopNotifyFailed = 4 // 处理成功但回调通知失败
+)
+
+// 为处理命令加上 saveas 参数，将处理结果保存到 bucket:key
+func FopSaveas(fop, bucket, key string) string {
+	return fop + "|saveas/" + encodeURI(bucket+":"+key)
+}
+
+// 持久化处理参数
+type PfopArgs struct {
+	Fops      []string // 处理命令，可以使用 FopSaveas 指定结果的保存位置
+	NotifyUrl string   // 处理完成后的回调地址
+	Pipeline  string   // 私有队列，为空时使用公共队列
+	Force     bool     // 结果文件已存在时是否强制覆盖
+}
+
+// 对已有的文件发起持久化处理，返回的 persistentId 用于查询处理状态。
+//
+// ctx  是请求的上下文。
+// key  是要处理的文件的访问路径。
+// args 是处理参数。
+func (p Bucket) Pfop(ctx context.Context, key string, args *PfopArgs) (persistentId string, err error) {
+	form := map[string][]string{
+		"bucket": {p.Name},
+		"key":    {key},
+		"fops":   {strings.Join(args.Fops, ";")},
+	}
+	if args.NotifyUrl != "" {
+		form["notifyURL"] = []string{args.NotifyUrl}
+	}
+	if args.Pipeline != "" {
+		form["pipeline"] = []string{args.Pipeline}
+	}
+	if args.Force {
+		form["force"] = []string{"1"}
+	}
+	var ret struct {
+		PersistentId string `json:"persistentId"`
+	}
+	err = p.Conn.CallWithForm(ctx, &ret, "POST", p.Conn.APIHost+"/pfop/", form)
+	return ret.PersistentId, err
+}
+
+// ----------------------------------------------------------
+
+// 单条处理命令的结果
+type PrefopItem struct {
+	Cmd       string `json:"cmd"`
+	Code      int    `json:"code"`
+	Desc      string `json:"desc"`
+	Error     string `json:"error,omitempty"`
+	Hash      string `json:"hash,omitempty"`
+	Key       string `json:"key,omitempty"` // 处理结果保存的 key
+	ReturnOld int    `json:"returnOld"`     // 为 1 时结果文件已存在，没有重新处理
+}
+
+// 持久化处理任务状态
+type PrefopRet struct {
+	Id          string       `json:"id"`
+	Code        int          `json:"code"`
+	Desc        string       `json:"desc"`
+	InputKey    string       `json:"inputKey"`
+	InputBucket string       `json:"inputBucket"`
+	Pipeline    string       `json:"pipeline"`
+	Reqid       string       `json:"reqid"`
+	Items       []PrefopItem `json:"items"`
+}
+
+// 任务是否已经结束，结束后通过 Code 和 Items 判断每条命令是否成功
+func (ret *PrefopRet) Done() bool {
+	return ret.Code != PrefopWaiting && ret.Code != PrefopProcessing
+}
+
+// 查询持久化处理任务的状态。
+func (p *Client) Prefop(ctx context.Context, persistentId string) (ret PrefopRet, err error) {
+	err = p.Call(ctx, &ret, "GET", p.APIHost+"/status/get/prefop?id="+url.QueryEscape(persistentId))
+	return
+}
+
+// WaitPrefop 的最小查询间隔
+var minPrefopInterval = time.Second
+
+// 每隔 interval 查询一次任务状态，直到任务结束或者 ctx 被取消，interval 小于 1 秒时按 1 秒查询。
+func (p *Client) WaitPrefop(ctx context.Context, persistentId string, interval time.Duration) (ret PrefopRet, err error) {
+	if interval < minPrefopInterval {
+		interval = minPrefopInterval
+	}
+	for {
+		if ret, err = p.Prefop(ctx, persistentId); err != nil || ret.Done() {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return ret, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// ----------------------------------------------------------
@@ -0,0 +1,67 @@
+package kodo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPfop(t *testing.T) {
+
+	var form url.Values
+	polls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/pfop/":
+			req.ParseForm()
+			form = req.PostForm
+			w.Write([]byte(`{"persistentId":"z0.abc"}`))
+		case "/status/get/prefop":
+			polls++
+			if polls < 3 {
+				w.Write([]byte(`{"id":"z0.abc","code":2}`))
+				return
+			}
+			w.Write([]byte(`{"id":"z0.abc","code":0,"items":[{"cmd":"avthumb/mp4","code":0,"key":"out.mp4"}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := NewWithoutZone(&Config{AccessKey: "ak", SecretKey: "sk", APIHost: srv.URL})
+	ctx := context.Background()
+
+	fop := FopSaveas("avthumb/mp4", "media", "out.mp4")
+	if fop != "avthumb/mp4|saveas/"+encodeURI("media:out.mp4") {
+		t.Fatal("unexpected fop:", fop)
+	}
+	id, err := c.Bucket("media").Pfop(ctx, "in.mov", &PfopArgs{Fops: []string{fop, "vframe/jpg/offset/1"}, Force: true})
+	if err != nil || id != "z0.abc" {
+		t.Fatal("Pfop:", id, err)
+	}
+	if form.Get("bucket") != "media" || form.Get("key") != "in.mov" || form.Get("fops") != fop+";vframe/jpg/offset/1" || form.Get("force") != "1" {
+		t.Fatal("unexpected pfop form:", form)
+	}
+
+	// interval 不大于 0 时按最小间隔查询，不会空转
+	defer func(d time.Duration) { minPrefopInterval = d }(minPrefopInterval)
+	minPrefopInterval = 10 * time.Millisecond
+	start := time.Now()
+	ret, err := c.WaitPrefop(ctx, id, 0)
+	if err != nil || !ret.Done() || polls != 3 || len(ret.Items) != 1 || ret.Items[0].Key != "out.mp4" {
+		t.Fatal("WaitPrefop:", ret, polls, err)
+	}
+	if elapsed := time.Since(start); elapsed < 2*minPrefopInterval {
+		t.Fatal("WaitPrefop should wait at least the minimum interval between polls:", elapsed)
+	}
+
+	polls = 0
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+	defer cancel()
+	if _, err = c.WaitPrefop(ctx, id, time.Hour); err != context.DeadlineExceeded {
+		t.Fatal("WaitPrefop should stop when ctx is done:", err)
+	}
+}
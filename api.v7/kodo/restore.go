@@ -0,0 +1,30 @@
+package kodo
+
+import (
+	"context"
+	"strconv"
+)
+
+// ----------------------------------------------------------
+
+// 解冻归档存储的文件，解冻完成后 freezeAfterDays 天内可以下载，取值范围为 1 到 7。
+// 解冻是异步的，可以通过 StatDetail 返回的 RestoreStatus 查询进度
+//
+// ctx 是请求的上下文。
+// key 是要解冻的文件的访问路径。
+func (p Bucket) RestoreAr(ctx context.Context, key string, freezeAfterDays int) (err error) {
+	return p.Conn.Call(ctx, nil, "POST", p.Conn.RSHost+URIRestoreAr(p.Name, key, freezeAfterDays))
+}
+
+func (p Bucket) BatchRestoreAr(ctx context.Context, freezeAfterDays int, keys ...string) (ret []BatchItemRet, err error) {
+
+	return p.batchKeys(ctx, keys, func(key string) string {
+		return URIRestoreAr(p.Name, key, freezeAfterDays)
+	})
+}
+
+func URIRestoreAr(bucket, key string, freezeAfterDays int) string {
+	return "/restoreAr/" + encodeURI(bucket+":"+key) + "/freezeAfterDays/" + strconv.Itoa(freezeAfterDays)
+}
+
+// ----------------------------------------------------------
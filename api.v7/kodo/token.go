@@ -28,8 +28,22 @@ type GetPolicy struct {
 	Expires uint32
 }
 
+// 生成私有下载 url。使用 Credentials 且获取密钥失败时返回空字符串而不是未签名的 url，
+// 调用方需要检查返回值是否为空；需要错误信息时使用 MakePrivateUrlE
 func (p *Client) MakePrivateUrl(baseUrl string, policy *GetPolicy) (privateUrl string) {
 
+	privateUrl, _ = p.MakePrivateUrlE(baseUrl, policy)
+	return
+}
+
+// 生成私有下载 url，获取密钥失败时返回错误
+func (p *Client) MakePrivateUrlE(baseUrl string, policy *GetPolicy) (privateUrl string, err error) {
+
+	mac, err := p.retrieveMac()
+	if err != nil {
+		return
+	}
+
 	var expires int64
 	if policy == nil || policy.Expires == 0 {
 		expires = 3600
@@ -45,8 +59,8 @@ func (p *Client) MakePrivateUrl(baseUrl string, policy *GetPolicy) (privateUrl s
 	}
 	baseUrl += strconv.FormatInt(deadline, 10)
 
-	token := qbox.Sign(p.mac, []byte(baseUrl))
-	return baseUrl + "&token=" + token
+	token := qbox.Sign(mac, []byte(baseUrl))
+	return baseUrl + "&token=" + token, nil
 }
 
 // --------------------------------------------------------------------------------
@@ -95,10 +109,21 @@ func (p *Client) MakeUptokenWithSafe(policy *PutPolicy) (token string, err error
 	}
 	rr.Expires += uint32(time.Now().Unix())
 	b, _ := json.Marshal(&rr)
-	token = qbox.SignWithData(p.mac, b)
+	mac, err := p.retrieveMac()
+	if err != nil {
+		return
+	}
+	token = qbox.SignWithData(mac, b)
 	return
 }
 
+func (p *Client) retrieveMac() (*qbox.Mac, error) {
+	if p.Credentials == nil {
+		return p.mac, nil
+	}
+	return p.Credentials.Retrieve()
+}
+
 func getBucketNameFromPutPolicy(policy *PutPolicy) (bucketName string) {
 	scope := policy.Scope
 	bucketName = strings.Split(scope, ":")[0]
@@ -5,7 +5,10 @@ import (
 	"encoding/base64"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
+
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/auth/qbox"
 )
 
 func init() {
@@ -68,3 +71,28 @@ func testClient_MakeUptokenBucket(t *testing.T) {
 		t.Fatal("make up token fail")
 	}
 }
+
+type failingProvider struct{}
+
+func (failingProvider) Retrieve() (*qbox.Mac, error) {
+
+	return nil, qbox.ErrNoCredentials
+}
+
+func TestMakePrivateUrlCredentialsError(t *testing.T) {
+
+	c := NewWithoutZone(&Config{AccessKey: "ak", SecretKey: "sk", Credentials: failingProvider{}})
+	baseUrl := MakeBaseUrl("example.com", "a.car")
+	if privateUrl, err := c.MakePrivateUrlE(baseUrl, nil); err != qbox.ErrNoCredentials || privateUrl != "" {
+		t.Fatal("MakePrivateUrlE should not fall back to AccessKey/SecretKey:", privateUrl, err)
+	}
+	if privateUrl := c.MakePrivateUrl(baseUrl, nil); privateUrl != "" {
+		t.Fatal("MakePrivateUrl should return empty url:", privateUrl)
+	}
+
+	c = NewWithoutZone(&Config{AccessKey: "ak", SecretKey: "sk"})
+	privateUrl, err := c.MakePrivateUrlE(baseUrl, nil)
+	if err != nil || !strings.HasPrefix(privateUrl, baseUrl+"?e=") || !strings.Contains(privateUrl, "&token=ak:") {
+		t.Fatal("MakePrivateUrlE:", privateUrl, err)
+	}
+}
@@ -0,0 +1,94 @@
+package kodo
+
+import (
+	"context"
+	"net/url"
+)
+
+// ----------------------------------------------------------
+
+// 存储区域
+const (
+	RegionZ0  = "z0"  // 华东
+	RegionZ1  = "z1"  // 华北
+	RegionZ2  = "z2"  // 华南
+	RegionNa0 = "na0" // 北美
+	RegionAs0 = "as0" // 东南亚
+)
+
+// 存储空间信息
+type BucketInfo struct {
+	Region      string `json:"region"`
+	Zone        string `json:"zone"`
+	Private     int    `json:"private"` // 1 为私有空间
+	Protected   int    `json:"protected"`
+	NoIndexPage int    `json:"no_index_page"`
+	MaxAge      int    `json:"max_age"`
+	Source      string `json:"source"` // 镜像源
+	Host        string `json:"host"`   // 回源 Host
+	Ctime       int64  `json:"ctime"`
+}
+
+// 是否为私有空间
+func (info *BucketInfo) IsPrivate() bool {
+	return info.Private == 1
+}
+
+// 创建存储空间。
+//
+// ctx    是请求的上下文。
+// name   是存储空间名称，需要全局唯一。
+// region 是存储区域，如 RegionZ0，为空时使用默认区域。
+func (p *Client) CreateBucket(ctx context.Context, name, region string) (err error) {
+	uri := "/mkbucketv3/" + url.PathEscape(name)
+	if region != "" {
+		uri += "/region/" + region
+	}
+	return p.Call(ctx, nil, "POST", p.UCHost+uri)
+}
+
+// 列举当前账号的存储空间，shared 为 true 时同时返回其他账号授权的存储空间。
+func (p *Client) Buckets(ctx context.Context, shared bool) (buckets []string, err error) {
+	uri := "/buckets"
+	if shared {
+		uri += "?shared=rd"
+	}
+	err = p.Call(ctx, &buckets, "POST", p.UCHost+uri)
+	return
+}
+
+// 删除存储空间，存储空间中还有文件时会失败。
+func (p *Client) DropBucket(ctx context.Context, name string) (err error) {
+	return p.Call(ctx, nil, "POST", p.UCHost+"/drop/"+url.PathEscape(name))
+}
+
+// 设置存储空间为私有空间或公开空间。
+func (p *Client) SetBucketPrivate(ctx context.Context, name string, private bool) (err error) {
+	v := "0"
+	if private {
+		v = "1"
+	}
+	return p.CallWithForm(ctx, nil, "POST", p.UCHost+"/private", map[string][]string{
+		"bucket":  {name},
+		"private": {v},
+	})
+}
+
+// 获取存储空间信息，包括所在区域和访问权限。
+func (p *Client) BucketInfo(ctx context.Context, name string) (info BucketInfo, err error) {
+	err = p.Call(ctx, &info, "POST", p.UCHost+"/v2/bucketInfo?bucket="+url.QueryEscape(name))
+	return
+}
+
+// 列举存储空间绑定的域名。
+func (p *Client) BucketDomains(ctx context.Context, name string) (domains []string, err error) {
+	err = p.Call(ctx, &domains, "GET", p.APIHost+"/v6/domain/list?tbl="+url.QueryEscape(name))
+	return
+}
+
+// 为存储空间绑定域名，域名需要已经完成备案和 CNAME 配置。
+func (p *Client) BindBucketDomain(ctx context.Context, name, domain string) (err error) {
+	return p.Call(ctx, nil, "POST", p.UCHost+"/publish/"+encodeURI(domain)+"/from/"+url.PathEscape(name))
+}
+
+// ----------------------------------------------------------
@@ -0,0 +1,73 @@
+package kodo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBucketManagement(t *testing.T) {
+
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(req.Header.Get("Authorization"), "QBox ak:") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		req.ParseForm()
+		requests = append(requests, req.Method+" "+req.URL.RequestURI()+" "+req.PostForm.Encode())
+		w.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/buckets", "/v6/domain/list":
+			w.Write([]byte(`["a","b"]`))
+		case "/v2/bucketInfo":
+			w.Write([]byte(`{"region":"z2","private":1}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := NewWithoutZone(&Config{AccessKey: "ak", SecretKey: "sk", UCHost: srv.URL, APIHost: srv.URL})
+	ctx := context.Background()
+
+	if err := c.CreateBucket(ctx, "miner-1", RegionZ2); err != nil {
+		t.Fatal(err)
+	}
+	buckets, err := c.Buckets(ctx, true)
+	if err != nil || len(buckets) != 2 {
+		t.Fatal("Buckets:", buckets, err)
+	}
+	if err = c.SetBucketPrivate(ctx, "miner-1", true); err != nil {
+		t.Fatal(err)
+	}
+	info, err := c.BucketInfo(ctx, "miner-1")
+	if err != nil || info.Region != RegionZ2 || !info.IsPrivate() {
+		t.Fatal("BucketInfo:", info, err)
+	}
+	domains, err := c.BucketDomains(ctx, "miner-1")
+	if err != nil || len(domains) != 2 {
+		t.Fatal("BucketDomains:", domains, err)
+	}
+	if err = c.BindBucketDomain(ctx, "miner-1", "cdn.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.DropBucket(ctx, "miner-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"POST /mkbucketv3/miner-1/region/z2 ",
+		"POST /buckets?shared=rd ",
+		"POST /private bucket=miner-1&private=1",
+		"POST /v2/bucketInfo?bucket=miner-1 ",
+		"GET /v6/domain/list?tbl=miner-1 ",
+		"POST /publish/" + encodeURI("cdn.example.com") + "/from/miner-1 ",
+		"POST /drop/miner-1 ",
+	}
+	if strings.Join(requests, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected requests:\n%s", strings.Join(requests, "\n"))
+	}
+}
@@ -287,11 +287,12 @@ const (
 	TypeNormal = iota
 	TypeLine
 	TypeArchive
+	TypeDeepArchive
 	FileTypeMax
 )
 
 func URIChangeType(bucket, key string, Type FileType) string {
-	return "/chgm/" + encodeURI(bucket+":"+key) + "/type/" + fmt.Sprint(Type)
+	return "/chtype/" + encodeURI(bucket+":"+key) + "/type/" + fmt.Sprint(Type)
 }
 
 // ----------------------------------------------------------
@@ -0,0 +1,199 @@
+package kodo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/auth/qbox"
+)
+
+// ----------------------------------------------------------
+
+// 上传回调中的魔法变量，对应 CallbackBody/CallbackBodyJSON 生成的回调内容
+type CallbackInfo struct {
+	Key          string // $(key)
+	Hash         string // $(etag)
+	Fsize        int64  // $(fsize)
+	MimeType     string // $(mimeType)
+	Bucket       string // $(bucket)
+	EndUser      string // $(endUser)
+	PersistentId string // $(persistentId)
+
+	Vars   map[string]string // 自定义变量 $(x:foo)，key 不含 "x:" 前缀
+	Values map[string]string // 回调内容中的全部字段
+}
+
+var callbackFields = [][2]string{
+	{"key", "$(key)"},
+	{"hash", "$(etag)"},
+	{"fsize", "$(fsize)"},
+	{"mimeType", "$(mimeType)"},
+	{"bucket", "$(bucket)"},
+	{"endUser", "$(endUser)"},
+	{"persistentId", "$(persistentId)"},
+}
+
+// 生成 application/x-www-form-urlencoded 格式的 PutPolicy.CallbackBody，
+// vars 为需要回传的自定义变量名，不含 "x:" 前缀
+func CallbackBody(vars ...string) string {
+
+	parts := make([]string, 0, len(callbackFields)+len(vars))
+	for _, f := range callbackFields {
+		parts = append(parts, f[0]+"="+f[1])
+	}
+	for _, v := range vars {
+		parts = append(parts, "x:"+v+"=$(x:"+v+")")
+	}
+	return strings.Join(parts, "&")
+}
+
+// 生成 application/json 格式的 PutPolicy.CallbackBody，此时 PutPolicy.CallbackBodyType 需要设置为 "application/json"
+func CallbackBodyJSON(vars ...string) string {
+
+	parts := make([]string, 0, len(callbackFields)+len(vars))
+	for _, f := range callbackFields {
+		if f[0] == "fsize" {
+			parts = append(parts, strconv.Quote(f[0])+":"+f[1])
+		} else {
+			parts = append(parts, strconv.Quote(f[0])+":"+strconv.Quote(f[1]))
+		}
+	}
+	for _, v := range vars {
+		parts = append(parts, strconv.Quote("x:"+v)+":"+strconv.Quote("$(x:"+v+")"))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// 解析回调内容，根据 Content-Type 按 JSON 或表单格式解析
+func ParseCallback(req *http.Request) (info *CallbackInfo, err error) {
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return
+	}
+	values := make(map[string]string)
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		var m map[string]interface{}
+		if err = json.Unmarshal(body, &m); err != nil {
+			return
+		}
+		for k, v := range m {
+			if s, ok := v.(string); ok {
+				values[k] = s
+			} else {
+				b, _ := json.Marshal(v)
+				values[k] = string(b)
+			}
+		}
+	} else {
+		form, err2 := url.ParseQuery(string(body))
+		if err2 != nil {
+			return nil, err2
+		}
+		for k := range form {
+			values[k] = form.Get(k)
+		}
+	}
+
+	info = &CallbackInfo{
+		Key:          values["key"],
+		Hash:         values["hash"],
+		MimeType:     values["mimeType"],
+		Bucket:       values["bucket"],
+		EndUser:      values["endUser"],
+		PersistentId: values["persistentId"],
+		Vars:         make(map[string]string),
+		Values:       values,
+	}
+	if fsize := values["fsize"]; fsize != "" {
+		if info.Fsize, err = strconv.ParseInt(fsize, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid fsize %q: %v", fsize, err)
+		}
+	}
+	for k, v := range values {
+		if strings.HasPrefix(k, "x:") {
+			info.Vars[strings.TrimPrefix(k, "x:")] = v
+		}
+	}
+	return
+}
+
+// ----------------------------------------------------------
+
+// 处理上传回调，返回值会以 JSON 格式作为上传结果返回给客户端
+type CallbackFunc func(ctx context.Context, info *CallbackInfo) (ret interface{}, err error)
+
+// 接收上传回调的 http.Handler，校验 QBox 或 Qiniu 签名后解析回调内容并调用 Func
+type CallbackHandler struct {
+	Credentials qbox.CredentialsProvider
+	Func        CallbackFunc
+}
+
+func NewCallbackHandler(credentials qbox.CredentialsProvider, fn CallbackFunc) *CallbackHandler {
+
+	return &CallbackHandler{Credentials: credentials, Func: fn}
+}
+
+// 校验回调请求的签名
+func VerifyCallback(mac *qbox.Mac, req *http.Request) (bool, error) {
+
+	if strings.HasPrefix(req.Header.Get("Authorization"), "Qiniu ") {
+		return mac.VerifyCallbackV2(req)
+	}
+	return mac.VerifyCallback(req)
+}
+
+func (h *CallbackHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	if req.Method != http.MethodPost {
+		writeCallbackError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	mac, err := h.Credentials.Retrieve()
+	if err != nil {
+		writeCallbackError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ok, err := VerifyCallback(mac, req)
+	if err != nil || !ok {
+		writeCallbackError(w, http.StatusUnauthorized, "invalid callback signature")
+		return
+	}
+	info, err := ParseCallback(req)
+	if err != nil {
+		writeCallbackError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ret, err := h.Func(req.Context(), info)
+	if err != nil {
+		writeCallbackError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if ret == nil {
+		ret = map[string]string{}
+	}
+	b, err := json.Marshal(ret)
+	if err != nil {
+		writeCallbackError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+func writeCallbackError(w http.ResponseWriter, code int, msg string) {
+
+	b, _ := json.Marshal(map[string]string{"error": msg})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(b)
+}
+
+// ----------------------------------------------------------
@@ -0,0 +1,97 @@
+package kodo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/auth/qbox"
+)
+
+func signedCallback(t *testing.T, mac *qbox.Mac, scheme, contentType, body string) *http.Request {
+
+	req := httptest.NewRequest("POST", "http://callback.example.com/qiniu/callback?id=1", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	var token string
+	var err error
+	if scheme == "Qiniu" {
+		token, err = mac.SignRequestV2(req)
+	} else {
+		token, err = mac.SignRequest(req, true)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", scheme+" "+token)
+	return req
+}
+
+func TestCallbackHandler(t *testing.T) {
+
+	mac := qbox.NewMac("ak", "sk")
+	var got *CallbackInfo
+	h := NewCallbackHandler(mac, func(ctx context.Context, info *CallbackInfo) (interface{}, error) {
+		got = info
+		if info.Key == "fail" {
+			return nil, errors.New("rejected")
+		}
+		return map[string]string{"key": info.Key}, nil
+	})
+
+	cases := []struct {
+		scheme, contentType, body string
+	}{
+		{"QBox", "application/x-www-form-urlencoded", "key=a%2Fb&hash=Fh8x&fsize=1024&bucket=test&x:foo=bar"},
+		{"Qiniu", "application/x-www-form-urlencoded", "key=a%2Fb&hash=Fh8x&fsize=1024&bucket=test&x:foo=bar"},
+		{"QBox", "application/json", `{"key":"a/b","hash":"Fh8x","fsize":1024,"bucket":"test","x:foo":"bar"}`},
+		{"Qiniu", "application/json", `{"key":"a/b","hash":"Fh8x","fsize":1024,"bucket":"test","x:foo":"bar"}`},
+	}
+	for _, c := range cases {
+		got = nil
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, signedCallback(t, mac, c.scheme, c.contentType, c.body))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s: unexpected status %d: %s", c.scheme, c.contentType, w.Code, w.Body.String())
+		}
+		if got == nil || got.Key != "a/b" || got.Hash != "Fh8x" || got.Fsize != 1024 || got.Bucket != "test" || got.Vars["foo"] != "bar" {
+			t.Fatalf("%s %s: unexpected callback info %+v", c.scheme, c.contentType, got)
+		}
+		var ret map[string]string
+		if err := json.Unmarshal(w.Body.Bytes(), &ret); err != nil || ret["key"] != "a/b" {
+			t.Fatalf("%s %s: unexpected return body %s", c.scheme, c.contentType, w.Body.String())
+		}
+	}
+
+	got = nil
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, signedCallback(t, qbox.NewMac("ak", "other"), "QBox", "application/x-www-form-urlencoded", "key=a"))
+	if w.Code != http.StatusUnauthorized || got != nil {
+		t.Fatal("callback with invalid signature should be rejected:", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, signedCallback(t, mac, "QBox", "application/x-www-form-urlencoded", "key=fail"))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatal("callback returning error should fail:", w.Code)
+	}
+}
+
+func TestCallbackBody(t *testing.T) {
+
+	body := CallbackBody("foo")
+	if !strings.Contains(body, "hash=$(etag)") || !strings.HasSuffix(body, "&x:foo=$(x:foo)") {
+		t.Fatal("unexpected callback body:", body)
+	}
+	var m map[string]interface{}
+	jsonBody := strings.Replace(CallbackBodyJSON("foo"), "$(fsize)", "1", 1)
+	if err := json.Unmarshal([]byte(jsonBody), &m); err != nil {
+		t.Fatal("invalid json callback body:", jsonBody, err)
+	}
+	if m["x:foo"] != "$(x:foo)" || m["fsize"] != float64(1) {
+		t.Fatal("unexpected json callback body:", jsonBody)
+	}
+}
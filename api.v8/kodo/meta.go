@@ -0,0 +1,118 @@
+package kodo
+
+import (
+	. "context"
+
+	kodov7 "github.com/qiniupd/qiniu-go-sdk/api.v7/kodo"
+)
+
+// ----------------------------------------------------------
+
+// 对象状态，禁用的对象无法下载
+const (
+	StatusEnabled  = kodov7.StatusEnabled
+	StatusDisabled = kodov7.StatusDisabled
+)
+
+// 归档存储对象的解冻状态，对应 Entry.RestoreStatus
+const (
+	RestoreStatusFrozen    = kodov7.RestoreStatusFrozen
+	RestoreStatusRestoring = kodov7.RestoreStatusRestoring
+	RestoreStatusRestored  = kodov7.RestoreStatusRestored
+)
+
+// 文件的详细属性，与 api.v7/kodo 共用同一实现
+type EntryDetail = kodov7.EntryDetail
+
+type BatchStatDetailItemRet = kodov7.BatchStatDetailItemRet
+
+// v7 返回 rs 请求与当前 Bucket 相同的 api.v7/kodo 空间对象
+func (p Bucket) v7() kodov7.Bucket {
+	c := &kodov7.Client{Client: p.Conn.Client, Config: kodov7.Config{RSHost: p.Conn.RSHost}}
+	return c.Bucket(p.Name)
+}
+
+func batchItemRets(rets []kodov7.BatchItemRet, err error) ([]BatchItemRet, error) {
+
+	ret := make([]BatchItemRet, len(rets))
+	for i, r := range rets {
+		ret[i] = BatchItemRet(r)
+	}
+	return ret, err
+}
+
+// 取文件的详细属性，包括自定义元信息、存储类型、状态、md5 和解冻状态。
+//
+// ctx 是请求的上下文。
+// key 是要访问的文件的访问路径。
+func (p Bucket) StatDetail(ctx Context, key string) (entry EntryDetail, err error) {
+	return p.v7().StatDetail(ctx, key)
+}
+
+// 设置文件的自定义元信息，meta 的 key 不需要 x-qn-meta- 前缀。
+// 设置后会覆盖文件已有的全部自定义元信息
+//
+// ctx  是请求的上下文。
+// key  是要修改的文件的访问路径。
+// meta 是要设置的元信息。
+func (p Bucket) SetMeta(ctx Context, key string, meta map[string]string) (err error) {
+	return p.v7().SetMeta(ctx, key, meta)
+}
+
+// 修改文件的存储类型。
+//
+// ctx      是请求的上下文。
+// key      是要修改的文件的访问路径。
+// fileType 是新的存储类型，可以是 TypeNormal、TypeLine、TypeArchive 或 TypeDeepArchive。
+func (p Bucket) ChangeType(ctx Context, key string, fileType FileType) (err error) {
+	return p.v7().ChangeType(ctx, key, kodov7.FileType(fileType))
+}
+
+// 设置文件在指定天数后自动删除，days 为 0 时取消自动删除。
+func (p Bucket) DeleteAfterDays(ctx Context, key string, days int) (err error) {
+	return p.v7().DeleteAfterDays(ctx, key, days)
+}
+
+// 修改文件状态，status 为 StatusEnabled 或 StatusDisabled。
+func (p Bucket) ChangeStatus(ctx Context, key string, status int) (err error) {
+	return p.v7().ChangeStatus(ctx, key, status)
+}
+
+// ----------------------------------------------------------
+
+func (p Bucket) BatchStatDetail(ctx Context, keys ...string) (ret []BatchStatDetailItemRet, err error) {
+	return p.v7().BatchStatDetail(ctx, keys...)
+}
+
+func (p Bucket) BatchSetMeta(ctx Context, meta map[string]string, keys ...string) (ret []BatchItemRet, err error) {
+	return batchItemRets(p.v7().BatchSetMeta(ctx, meta, keys...))
+}
+
+func (p Bucket) BatchChangeType(ctx Context, fileType FileType, keys ...string) (ret []BatchItemRet, err error) {
+	return batchItemRets(p.v7().BatchChangeType(ctx, kodov7.FileType(fileType), keys...))
+}
+
+func (p Bucket) BatchDeleteAfterDays(ctx Context, days int, keys ...string) (ret []BatchItemRet, err error) {
+	return batchItemRets(p.v7().BatchDeleteAfterDays(ctx, days, keys...))
+}
+
+func (p Bucket) BatchChangeStatus(ctx Context, status int, keys ...string) (ret []BatchItemRet, err error) {
+	return batchItemRets(p.v7().BatchChangeStatus(ctx, status, keys...))
+}
+
+// ----------------------------------------------------------
+
+// mime 为空时只修改自定义元信息，meta 的 key 不需要 x-qn-meta- 前缀
+func URIChangeMeta(bucket, key, mime string, meta map[string]string) string {
+	return kodov7.URIChangeMeta(bucket, key, mime, meta)
+}
+
+func URIDeleteAfterDays(bucket, key string, days int) string {
+	return kodov7.URIDeleteAfterDays(bucket, key, days)
+}
+
+func URIChangeStatus(bucket, key string, status int) string {
+	return kodov7.URIChangeStatus(bucket, key, status)
+}
+
+// ----------------------------------------------------------
@@ -0,0 +1,75 @@
+package kodo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetaURIs(t *testing.T) {
+
+	entry := encodeURI("bucket:a/b.txt")
+	cases := [][2]string{
+		{URIChangeType("bucket", "a/b.txt", TypeArchive), "/chtype/" + entry + "/type/2"},
+		{URIChangeMeta("bucket", "a/b.txt", "", map[string]string{"b": "2", "a": "1"}),
+			"/chgm/" + entry + "/x-qn-meta-a/" + encodeURI("1") + "/x-qn-meta-b/" + encodeURI("2")},
+		{URIChangeMeta("bucket", "a/b.txt", "text/plain", nil), "/chgm/" + entry + "/mime/" + encodeURI("text/plain")},
+		{URIDeleteAfterDays("bucket", "a/b.txt", 7), "/deleteAfterDays/" + entry + "/7"},
+		{URIChangeStatus("bucket", "a/b.txt", StatusDisabled), "/chstatus/" + entry + "/status/1"},
+	}
+	for _, c := range cases {
+		if c[0] != c[1] {
+			t.Fatalf("unexpected uri %s, expected %s", c[0], c[1])
+		}
+	}
+}
+
+func TestRestoreURI(t *testing.T) {
+
+	uri := URIRestoreAr("bucket", "cold/sector-1", 3)
+	if uri != "/restoreAr/"+encodeURI("bucket:cold/sector-1")+"/freezeAfterDays/3" {
+		t.Fatal("unexpected uri:", uri)
+	}
+}
+
+func TestStatDetail(t *testing.T) {
+
+	var ops []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/batch":
+			req.ParseForm()
+			ops = req.PostForm["op"]
+			w.Write([]byte(`[{"code":200},{"code":612,"error":"no such file or directory"}]`))
+		default:
+			w.Write([]byte(`{"fsize":3,"type":2,"status":1,"md5":"abc","x-qn-meta":{"owner":"f01234"},"restoreStatus":1}`))
+		}
+	}))
+	defer srv.Close()
+
+	// 请求通过 api.v7/kodo 发出，使用的仍是 v8 Client 的 RSHost
+	b := New(0, &Config{AccessKey: "ak", SecretKey: "sk", RSHost: srv.URL}).Bucket("bucket")
+	entry, err := b.StatDetail(context.Background(), "a.car")
+	if err != nil || entry.Fsize != 3 || entry.Type != TypeArchive || entry.Status != StatusDisabled ||
+		entry.XQnMeta["owner"] != "f01234" || entry.RestoreStatus != RestoreStatusRestoring {
+		t.Fatal("StatDetail:", entry, err)
+	}
+
+	ret, err := b.BatchChangeType(context.Background(), TypeArchive, "a.car", "b.car")
+	if err != nil || len(ret) != 2 || ret[1].Code != 612 {
+		t.Fatal("BatchChangeType:", ret, err)
+	}
+	if len(ops) != 2 || ops[0] != URIChangeType("bucket", "a.car", TypeArchive) || ops[1] != URIChangeType("bucket", "b.car", TypeArchive) {
+		t.Fatal("unexpected batch ops:", ops)
+	}
+
+	ret, err = b.BatchRestoreAr(context.Background(), 1, "a.car", "b.car")
+	if err != nil || len(ret) != 2 || ret[1].Code != 612 {
+		t.Fatal("BatchRestoreAr:", ret, err)
+	}
+	if len(ops) != 2 || ops[0] != URIRestoreAr("bucket", "a.car", 1) || ops[1] != URIRestoreAr("bucket", "b.car", 1) {
+		t.Fatal("unexpected restore ops:", ops)
+	}
+}
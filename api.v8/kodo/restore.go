@@ -0,0 +1,28 @@
+package kodo
+
+import (
+	. "context"
+
+	kodov7 "github.com/qiniupd/qiniu-go-sdk/api.v7/kodo"
+)
+
+// ----------------------------------------------------------
+
+// 解冻归档存储的文件，解冻完成后 freezeAfterDays 天内可以下载，取值范围为 1 到 7。
+// 解冻是异步的，可以通过 StatDetail 返回的 RestoreStatus 查询进度
+//
+// ctx 是请求的上下文。
+// key 是要解冻的文件的访问路径。
+func (p Bucket) RestoreAr(ctx Context, key string, freezeAfterDays int) (err error) {
+	return p.v7().RestoreAr(ctx, key, freezeAfterDays)
+}
+
+func (p Bucket) BatchRestoreAr(ctx Context, freezeAfterDays int, keys ...string) (ret []BatchItemRet, err error) {
+	return batchItemRets(p.v7().BatchRestoreAr(ctx, freezeAfterDays, keys...))
+}
+
+func URIRestoreAr(bucket, key string, freezeAfterDays int) string {
+	return kodov7.URIRestoreAr(bucket, key, freezeAfterDays)
+}
+
+// ----------------------------------------------------------
@@ -4,7 +4,6 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
-	"strconv"
 	"strings"
 	"time"
 
@@ -37,23 +36,14 @@ func (p *Client) MakePrivateUrl(baseUrl string, policy *GetPolicy) (privateUrl s
 	} else {
 		expires = int64(policy.Expires)
 	}
-	deadline := time.Now().Unix() + expires
-
-	if strings.Contains(baseUrl, "?") {
-		baseUrl += "&e="
-	} else {
-		baseUrl += "?e="
-	}
-	baseUrl += strconv.FormatInt(deadline, 10)
-
-	token := qbox.Sign(p.mac, []byte(baseUrl))
-	return baseUrl + "&token=" + token
+	return SignPrivateUrl(p.mac, baseUrl, time.Now().Add(time.Duration(expires)*time.Second))
 }
 
 // --------------------------------------------------------------------------------
 
 type PutPolicy struct {
 	Scope               string   `json:"scope"`
+	IsPrefixalScope     uint8    `json:"isPrefixalScope,omitempty"` // 若非0, Scope 为 Bucket:KeyPrefix 的形式，允许上传以 KeyPrefix 开头的 key
 	Expires             uint32   `json:"deadline"`             // 截止时间（以秒为单位）
 	InsertOnly          uint16   `json:"insertOnly,omitempty"` // 若非0, 即使Scope为 Bucket:Key 的形式也是insert only
 	DetectMime          uint8    `json:"detectMime,omitempty"` // 若非0, 则服务端根据内容自动确定 MimeType
@@ -83,6 +73,8 @@ type PutPolicy struct {
 	Cond    string `json:"cond,omitempty"` //格式：condKey1=condVal1&condKey2=condVal2,支持hash、mime、fsize、putTime条件，只有条件匹配才会执行覆盖操作
 }
 
+// 生成上传凭证，policy.Expires 为相对于当前时间的有效期（秒），为 0 时为 3600。
+// 需要指定绝对截止时间或校验上传策略时使用 UptokenBuilder 或 SignUptoken
 func (p *Client) MakeUptoken(policy *PutPolicy) string {
 
 	var rr = *policy
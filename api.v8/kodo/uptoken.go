@@ -0,0 +1,316 @@
+package kodo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/auth/qbox"
+)
+
+// ----------------------------------------------------------
+
+const (
+	callbackBodyTypeForm = "application/x-www-form-urlencoded"
+	callbackBodyTypeJSON = "application/json"
+)
+
+// 上传策略中存在相互冲突或不合法的字段
+var ErrInvalidPutPolicy = errors.New("invalid put policy")
+
+// 校验上传策略，Expires 需要是绝对的截止时间
+func (policy *PutPolicy) Validate() error {
+
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: "+format, append([]interface{}{ErrInvalidPutPolicy}, args...)...)
+	}
+
+	bucket, _, hasKey := splitScope(policy.Scope)
+	if bucket == "" {
+		return invalid("scope is empty")
+	}
+	if policy.IsPrefixalScope != 0 && !hasKey {
+		return invalid("prefixal scope %q has no key prefix", policy.Scope)
+	}
+	if policy.Expires == 0 {
+		return invalid("deadline is not set")
+	}
+	if policy.SaveKey != "" && hasKey && policy.IsPrefixalScope == 0 {
+		return invalid("saveKey conflicts with key scope %q", policy.Scope)
+	}
+	if policy.CallbackUrl != "" && policy.CallbackBody == "" {
+		return invalid("callbackUrl without callbackBody")
+	}
+	if policy.CallbackUrl == "" && (policy.CallbackBody != "" || policy.CallbackHost != "" || policy.CallbackBodyType != "") {
+		return invalid("callback settings without callbackUrl")
+	}
+	switch policy.CallbackBodyType {
+	case "", callbackBodyTypeForm, callbackBodyTypeJSON:
+	default:
+		return invalid("unsupported callbackBodyType %q", policy.CallbackBodyType)
+	}
+	if policy.PersistentOps == "" && (policy.PersistentNotifyUrl != "" || policy.PersistentPipeline != "") {
+		return invalid("persistent settings without persistentOps")
+	}
+	switch policy.FileType {
+	case TypeNormal, TypeLine, TypeArchive, TypeDeepArchive:
+	default:
+		return invalid("unsupported fileType %d", policy.FileType)
+	}
+	if policy.DeleteAfterDays < 0 {
+		return invalid("deleteAfterDays %d is negative", policy.DeleteAfterDays)
+	}
+	if policy.FsizeLimit < 0 {
+		return invalid("fsizeLimit %d is negative", policy.FsizeLimit)
+	}
+	if policy.Checksum != "" {
+		parts := strings.SplitN(policy.Checksum, ":", 2)
+		if len(parts) != 2 || parts[0] != "MD5" && parts[0] != "SHA1" {
+			return invalid("unsupported checksum %q", policy.Checksum)
+		}
+	}
+	return nil
+}
+
+func splitScope(scope string) (bucket, key string, hasKey bool) {
+
+	pos := strings.Index(scope, ":")
+	if pos < 0 {
+		return scope, "", false
+	}
+	return scope[:pos], scope[pos+1:], true
+}
+
+// 校验上传策略并签名生成上传凭证，与 Client.MakeUptoken 不同，policy.Expires 为绝对的截止时间
+func SignUptoken(mac *qbox.Mac, policy *PutPolicy) (uptoken string, err error) {
+
+	if err = policy.Validate(); err != nil {
+		return
+	}
+	b, err := json.Marshal(policy)
+	if err != nil {
+		return
+	}
+	return qbox.SignWithData(mac, b), nil
+}
+
+// ----------------------------------------------------------
+
+const defaultUptokenTTL = time.Hour
+
+// 上传凭证构造器，所有设置方法都返回构造器本身以便链式调用。
+// 截止时间通过 Deadline 指定绝对时间，或通过 ExpiresIn 指定相对于 Build 时刻的有效期，都不指定时有效期为 1 小时
+type UptokenBuilder struct {
+	policy   PutPolicy
+	deadline time.Time
+	ttl      time.Duration
+}
+
+// 允许上传到空间中任意 key 的上传凭证，同名对象已存在时上传失败
+func NewUptokenBuilder(bucket string) *UptokenBuilder {
+
+	return &UptokenBuilder{policy: PutPolicy{Scope: bucket}}
+}
+
+// 只允许上传指定 key 的上传凭证，同名对象已存在时会被覆盖
+func NewKeyUptokenBuilder(bucket, key string) *UptokenBuilder {
+
+	return &UptokenBuilder{policy: PutPolicy{Scope: bucket + ":" + key}}
+}
+
+// 只允许上传以 prefix 开头的 key 的上传凭证，同名对象已存在时上传失败
+func NewPrefixUptokenBuilder(bucket, prefix string) *UptokenBuilder {
+
+	return &UptokenBuilder{policy: PutPolicy{Scope: bucket + ":" + prefix, IsPrefixalScope: 1}}
+}
+
+func (b *UptokenBuilder) Deadline(deadline time.Time) *UptokenBuilder {
+
+	b.deadline, b.ttl = deadline, 0
+	return b
+}
+
+func (b *UptokenBuilder) ExpiresIn(ttl time.Duration) *UptokenBuilder {
+
+	b.deadline, b.ttl = time.Time{}, ttl
+	return b
+}
+
+// 即使 scope 为 bucket:key 也不允许覆盖已有对象
+func (b *UptokenBuilder) InsertOnly() *UptokenBuilder {
+
+	b.policy.InsertOnly = 1
+	return b
+}
+
+func (b *UptokenBuilder) DetectMime() *UptokenBuilder {
+
+	b.policy.DetectMime = 1
+	return b
+}
+
+func (b *UptokenBuilder) FsizeLimit(limit int64) *UptokenBuilder {
+
+	b.policy.FsizeLimit = limit
+	return b
+}
+
+// 允许上传的 MimeType，多个之间用 ';' 分隔，以 '!' 开头表示禁止的类型
+func (b *UptokenBuilder) MimeLimit(limit string) *UptokenBuilder {
+
+	b.policy.MimeLimit = limit
+	return b
+}
+
+func (b *UptokenBuilder) SaveKey(saveKey string) *UptokenBuilder {
+
+	b.policy.SaveKey = saveKey
+	return b
+}
+
+func (b *UptokenBuilder) EndUser(endUser string) *UptokenBuilder {
+
+	b.policy.EndUser = endUser
+	return b
+}
+
+func (b *UptokenBuilder) ReturnUrl(returnUrl string) *UptokenBuilder {
+
+	b.policy.ReturnUrl = returnUrl
+	return b
+}
+
+func (b *UptokenBuilder) ReturnBody(returnBody string) *UptokenBuilder {
+
+	b.policy.ReturnBody = returnBody
+	return b
+}
+
+// 设置上传回调，bodyType 为空时按表单格式回调，body 可以使用 CallbackBody/CallbackBodyJSON 生成
+func (b *UptokenBuilder) Callback(url, body, bodyType string) *UptokenBuilder {
+
+	b.policy.CallbackUrl = url
+	b.policy.CallbackBody = body
+	b.policy.CallbackBodyType = bodyType
+	return b
+}
+
+func (b *UptokenBuilder) CallbackHost(host string) *UptokenBuilder {
+
+	b.policy.CallbackHost = host
+	return b
+}
+
+func (b *UptokenBuilder) Persistent(ops, notifyUrl, pipeline string) *UptokenBuilder {
+
+	b.policy.PersistentOps = ops
+	b.policy.PersistentNotifyUrl = notifyUrl
+	b.policy.PersistentPipeline = pipeline
+	return b
+}
+
+func (b *UptokenBuilder) DeleteAfterDays(days int) *UptokenBuilder {
+
+	b.policy.DeleteAfterDays = days
+	return b
+}
+
+func (b *UptokenBuilder) FileType(fileType FileType) *UptokenBuilder {
+
+	b.policy.FileType = fileType
+	return b
+}
+
+// 格式为 <HashName>:<HexHashValue>，支持 MD5 和 SHA1
+func (b *UptokenBuilder) Checksum(checksum string) *UptokenBuilder {
+
+	b.policy.Checksum = checksum
+	return b
+}
+
+// 返回校验后的上传策略，Expires 为绝对的截止时间
+func (b *UptokenBuilder) Policy() (policy PutPolicy, err error) {
+
+	policy = b.policy
+	deadline := b.deadline
+	if deadline.IsZero() {
+		ttl := b.ttl
+		if ttl <= 0 {
+			ttl = defaultUptokenTTL
+		}
+		deadline = time.Now().Add(ttl)
+	}
+	policy.Expires = uint32(deadline.Unix())
+	err = policy.Validate()
+	return
+}
+
+func (b *UptokenBuilder) Build(mac *qbox.Mac) (uptoken string, err error) {
+
+	policy, err := b.Policy()
+	if err != nil {
+		return
+	}
+	return SignUptoken(mac, &policy)
+}
+
+// 使用 Client 的密钥生成上传凭证
+func (p *Client) BuildUptoken(b *UptokenBuilder) (uptoken string, err error) {
+
+	return b.Build(p.mac)
+}
+
+// ----------------------------------------------------------
+
+// 从上传凭证中解析出的信息
+type UptokenInfo struct {
+	AccessKey       string
+	Bucket          string
+	Key             string // scope 为 bucket 时为空
+	HasKey          bool   // scope 为 bucket:key 形式时为 true，即使 Key 为空也只允许上传与之匹配的 key
+	IsPrefixalScope bool   // 为 true 时 Key 为允许上传的 key 前缀
+	Deadline        time.Time
+	Policy          PutPolicy
+}
+
+// 解析上传凭证，不校验签名，适合客户端在上传前检查凭证的范围和有效期
+func ParseUptokenInfo(uptoken string) (info *UptokenInfo, err error) {
+
+	policy, err := ParseUptoken(uptoken)
+	if err != nil {
+		return
+	}
+	bucket, key, hasKey := splitScope(policy.Scope)
+	info = &UptokenInfo{
+		AccessKey:       uptoken[:strings.Index(uptoken, ":")],
+		Bucket:          bucket,
+		Key:             key,
+		HasKey:          hasKey,
+		IsPrefixalScope: policy.IsPrefixalScope != 0,
+		Deadline:        time.Unix(int64(policy.Expires), 0),
+		Policy:          policy,
+	}
+	return
+}
+
+// 凭证在 now 时是否已经过期
+func (info *UptokenInfo) Expired(now time.Time) bool {
+
+	return now.After(info.Deadline)
+}
+
+// 凭证是否允许上传指定的 key
+func (info *UptokenInfo) Allows(key string) bool {
+
+	if !info.HasKey {
+		return true
+	}
+	if info.IsPrefixalScope {
+		return strings.HasPrefix(key, info.Key)
+	}
+	return key == info.Key
+}
+
+// ----------------------------------------------------------
@@ -0,0 +1,115 @@
+package kodo
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/auth/qbox"
+)
+
+func TestUptokenBuilder(t *testing.T) {
+
+	mac := qbox.NewMac("ak", "sk")
+	deadline := time.Unix(1893456000, 0)
+
+	uptoken, err := NewPrefixUptokenBuilder("bucket", "logs/").
+		Deadline(deadline).
+		Callback("http://example.com/callback", CallbackBody(), "").
+		FileType(TypeLine).
+		Build(mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := ParseUptokenInfo(uptoken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.AccessKey != "ak" || info.Bucket != "bucket" || info.Key != "logs/" || !info.IsPrefixalScope || !info.Deadline.Equal(deadline) {
+		t.Fatalf("unexpected uptoken info: %+v", info)
+	}
+	if !info.Allows("logs/a.log") || info.Allows("data/a.log") {
+		t.Fatal("prefixal scope should only allow keys under logs/")
+	}
+	if info.Expired(deadline.Add(-time.Second)) || !info.Expired(deadline.Add(time.Second)) {
+		t.Fatal("unexpected expiration")
+	}
+
+	before := time.Now()
+	policy, err := NewKeyUptokenBuilder("bucket", "a.txt").ExpiresIn(10 * time.Minute).Policy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := int64(policy.Expires) - before.Unix(); d < 599 || d > 601 {
+		t.Fatal("ExpiresIn should set a deadline relative to now:", d)
+	}
+}
+
+func TestUptokenInfoAllows(t *testing.T) {
+
+	mac := qbox.NewMac("ak", "sk")
+	cases := []struct {
+		scope   string
+		prefix  bool
+		allowed []string
+		denied  []string
+	}{
+		{scope: "bucket", allowed: []string{"a.txt", ""}},
+		{scope: "bucket:a.txt", allowed: []string{"a.txt"}, denied: []string{"b.txt", "a.txt.bak"}},
+		{scope: "bucket:", allowed: []string{""}, denied: []string{"a.txt"}},
+		{scope: "bucket:logs/", prefix: true, allowed: []string{"logs/a.log"}, denied: []string{"data/a.log"}},
+	}
+	for _, c := range cases {
+		policy := &PutPolicy{Scope: c.scope, Expires: 1893456000}
+		if c.prefix {
+			policy.IsPrefixalScope = 1
+		}
+		uptoken, err := SignUptoken(mac, policy)
+		if err != nil {
+			t.Fatal(c.scope, err)
+		}
+		info, err := ParseUptokenInfo(uptoken)
+		if err != nil {
+			t.Fatal(c.scope, err)
+		}
+		for _, key := range c.allowed {
+			if !info.Allows(key) {
+				t.Errorf("scope %q should allow key %q", c.scope, key)
+			}
+		}
+		for _, key := range c.denied {
+			if info.Allows(key) {
+				t.Errorf("scope %q should not allow key %q", c.scope, key)
+			}
+		}
+	}
+}
+
+func TestPutPolicyValidate(t *testing.T) {
+
+	cases := map[string]*UptokenBuilder{
+		"empty scope":           NewUptokenBuilder(""),
+		"callback without body": NewUptokenBuilder("bucket").Callback("http://example.com/callback", "", ""),
+		"body without callback": NewUptokenBuilder("bucket").Callback("", "key=$(key)", ""),
+		"bad callback type":     NewUptokenBuilder("bucket").Callback("http://example.com/callback", "key=$(key)", "text/plain"),
+		"saveKey with key":      NewKeyUptokenBuilder("bucket", "a.txt").SaveKey("$(etag)"),
+		"unknown fileType":      NewUptokenBuilder("bucket").FileType(FileTypeMax),
+		"notify without ops":    NewUptokenBuilder("bucket").Persistent("", "http://example.com/notify", ""),
+		"bad checksum":          NewUptokenBuilder("bucket").Checksum("CRC32:abcd"),
+	}
+	for name, b := range cases {
+		if _, err := b.Build(qbox.NewMac("ak", "sk")); !errors.Is(err, ErrInvalidPutPolicy) {
+			t.Fatal(name, "should be rejected:", err)
+		}
+	}
+
+	for _, fileType := range []FileType{TypeNormal, TypeLine, TypeArchive, TypeDeepArchive} {
+		if _, err := NewUptokenBuilder("bucket").FileType(fileType).Build(qbox.NewMac("ak", "sk")); err != nil {
+			t.Fatal("fileType", fileType, "should be accepted:", err)
+		}
+	}
+
+	if _, err := SignUptoken(qbox.NewMac("ak", "sk"), &PutPolicy{Scope: "bucket"}); !errors.Is(err, ErrInvalidPutPolicy) {
+		t.Fatal("policy without deadline should be rejected:", err)
+	}
+}
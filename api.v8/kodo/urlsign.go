@@ -0,0 +1,148 @@
+package kodo
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/auth/qbox"
+	urlx "github.com/qiniupd/qiniu-go-sdk/x/url.v7"
+)
+
+// ----------------------------------------------------------
+
+var (
+	ErrUrlExpired          = errors.New("url expired")
+	ErrUrlSignatureInvalid = errors.New("invalid url signature")
+	ErrNoTimestampKeys     = errors.New("no timestamp anti-leech keys")
+)
+
+// 根据自定义域名和文件的 key 获得 baseUrl。
+// domain 可以带上 http:// 或 https:// 前缀，不带时默认为 http；key 中除 '/' 外的特殊字符都会被转义
+func MakeDomainUrl(domain, key string) (baseUrl string) {
+
+	if !strings.HasPrefix(domain, "http://") && !strings.HasPrefix(domain, "https://") {
+		domain = "http://" + domain
+	}
+	return strings.TrimSuffix(domain, "/") + "/" + escapeKey(key)
+}
+
+func escapeKey(key string) string {
+
+	segs := strings.Split(key, "/")
+	for i, seg := range segs {
+		segs[i] = urlx.EscapeEx(seg, urlx.EncodePathSegment)
+	}
+	return strings.Join(segs, "/")
+}
+
+func appendQuery(rawUrl, query string) string {
+
+	if strings.Contains(rawUrl, "?") {
+		return rawUrl + "&" + query
+	}
+	return rawUrl + "?" + query
+}
+
+// ----------------------------------------------------------
+
+// 使用 e/token 参数对 baseUrl 签名，deadline 为链接的过期时间
+func SignPrivateUrl(mac *qbox.Mac, baseUrl string, deadline time.Time) (privateUrl string) {
+
+	baseUrl = appendQuery(baseUrl, "e="+strconv.FormatInt(deadline.Unix(), 10))
+	return baseUrl + "&token=" + qbox.Sign(mac, []byte(baseUrl))
+}
+
+// 校验 e/token 签名的私有链接，token 必须是链接的最后一个参数
+func VerifyPrivateUrl(mac *qbox.Mac, privateUrl string, now time.Time) error {
+
+	pos := strings.LastIndex(privateUrl, "&token=")
+	if pos < 0 {
+		return ErrUrlSignatureInvalid
+	}
+	baseUrl, token := privateUrl[:pos], privateUrl[pos+len("&token="):]
+	u, err := url.Parse(baseUrl)
+	if err != nil {
+		return err
+	}
+	e, err := strconv.ParseInt(u.Query().Get("e"), 10, 64)
+	if err != nil {
+		return ErrUrlSignatureInvalid
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(qbox.Sign(mac, []byte(baseUrl)))) != 1 {
+		return ErrUrlSignatureInvalid
+	}
+	if now.Unix() > e {
+		return ErrUrlExpired
+	}
+	return nil
+}
+
+// ----------------------------------------------------------
+
+// CDN 时间戳防盗链签名。
+// Keys[0] 用于签名，校验时接受任意一个 key，轮换密钥时把新 key 放在最前面，旧 key 在过渡期内保留
+type TimestampSigner struct {
+	Keys []string
+}
+
+func NewTimestampSigner(keys ...string) *TimestampSigner {
+
+	return &TimestampSigner{Keys: keys}
+}
+
+func timestampSign(key, path, t string) string {
+
+	sum := md5.Sum([]byte(key + path + t))
+	return hex.EncodeToString(sum[:])
+}
+
+// 为链接加上 sign 和 t 参数，deadline 为链接的过期时间
+func (s *TimestampSigner) SignUrl(rawUrl string, deadline time.Time) (signedUrl string, err error) {
+
+	if len(s.Keys) == 0 {
+		return "", ErrNoTimestampKeys
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return
+	}
+	t := strconv.FormatInt(deadline.Unix(), 16)
+	sign := timestampSign(s.Keys[0], u.EscapedPath(), t)
+	return appendQuery(rawUrl, "sign="+sign+"&t="+t), nil
+}
+
+// 校验带有 sign 和 t 参数的链接
+func (s *TimestampSigner) VerifyUrl(signedUrl string, now time.Time) error {
+
+	if len(s.Keys) == 0 {
+		return ErrNoTimestampKeys
+	}
+	u, err := url.Parse(signedUrl)
+	if err != nil {
+		return err
+	}
+	query := u.Query()
+	sign, t := query.Get("sign"), query.Get("t")
+	deadline, err := strconv.ParseInt(t, 16, 64)
+	if err != nil || sign == "" {
+		return ErrUrlSignatureInvalid
+	}
+	path := u.EscapedPath()
+	for _, key := range s.Keys {
+		if subtle.ConstantTimeCompare([]byte(strings.ToLower(sign)), []byte(timestampSign(key, path, t))) == 1 {
+			if now.Unix() > deadline {
+				return ErrUrlExpired
+			}
+			return nil
+		}
+	}
+	return ErrUrlSignatureInvalid
+}
+
+// ----------------------------------------------------------
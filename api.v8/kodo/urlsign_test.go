@@ -0,0 +1,69 @@
+package kodo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/auth/qbox"
+)
+
+func TestMakeDomainUrl(t *testing.T) {
+
+	cases := [][3]string{
+		{"cdn.example.com", "a/b c?.jpg", "http://cdn.example.com/a/b%20c%3F.jpg"},
+		{"https://cdn.example.com/", "中文#1", "https://cdn.example.com/%E4%B8%AD%E6%96%87%231"},
+	}
+	for _, c := range cases {
+		if u := MakeDomainUrl(c[0], c[1]); u != c[2] {
+			t.Fatal("MakeDomainUrl:", c[0], c[1], u)
+		}
+	}
+}
+
+func TestSignPrivateUrl(t *testing.T) {
+
+	mac := qbox.NewMac("ak", "sk")
+	now := time.Now()
+	privateUrl := SignPrivateUrl(mac, "http://cdn.example.com/a.jpg?imageView2/1", now.Add(time.Hour))
+
+	if err := VerifyPrivateUrl(mac, privateUrl, now); err != nil {
+		t.Fatal("VerifyPrivateUrl:", privateUrl, err)
+	}
+	if err := VerifyPrivateUrl(mac, privateUrl, now.Add(2*time.Hour)); err != ErrUrlExpired {
+		t.Fatal("expired url:", err)
+	}
+	if err := VerifyPrivateUrl(qbox.NewMac("ak", "other"), privateUrl, now); err != ErrUrlSignatureInvalid {
+		t.Fatal("wrong key:", err)
+	}
+}
+
+func TestTimestampSigner(t *testing.T) {
+
+	s := NewTimestampSigner("ac9e6deb8f4b8d4d1b1d1f94d1c7a4cb5d1a6e2a")
+	signed, err := s.SignUrl("http://cdn.example.com/DirectoryA/test.mp4", time.Unix(0x55f6516a, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signed != "http://cdn.example.com/DirectoryA/test.mp4?sign=0586ac1b73c8fde1a17ce4d65dd79555&t=55f6516a" {
+		t.Fatal("unexpected signed url:", signed)
+	}
+
+	now := time.Unix(0x55f6516a-60, 0)
+	if err := s.VerifyUrl(signed, now); err != nil {
+		t.Fatal("VerifyUrl:", signed, err)
+	}
+	if err := s.VerifyUrl(signed, now.Add(time.Hour)); err != ErrUrlExpired {
+		t.Fatal("expired url:", err)
+	}
+
+	rotated := NewTimestampSigner("new-key", s.Keys[0])
+	if err := rotated.VerifyUrl(signed, now); err != nil {
+		t.Fatal("url signed with old key should still be valid:", err)
+	}
+	if err := NewTimestampSigner("new-key").VerifyUrl(signed, now); err != ErrUrlSignatureInvalid {
+		t.Fatal("url signed with removed key:", err)
+	}
+	if _, err := NewTimestampSigner().SignUrl(signed, now); err != ErrNoTimestampKeys {
+		t.Fatal("signer without keys:", err)
+	}
+}
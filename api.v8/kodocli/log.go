@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+
+	"github.com/qiniupd/qiniu-go-sdk/x/kvlog.v1"
 )
 
 type Ilog interface {
@@ -86,6 +88,11 @@ func SetLogger(logger Ilog) {
 	elog = logger
 }
 
+// 设置结构化日志记录器，SDK 内部日志以消息形式输出到该记录器
+func SetKVLogger(logger *kvlog.Logger) {
+	elog = kvlog.ToPrinter(logger)
+}
+
 func init() {
 	if elog == nil {
 		elog = NewLogger()
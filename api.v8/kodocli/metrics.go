@@ -0,0 +1,27 @@
+package kodocli
+
+import (
+	"time"
+
+	"github.com/qiniupd/qiniu-go-sdk/x/metrics.v1"
+)
+
+func recordRetry(api string) {
+	if metrics.Enabled() {
+		metrics.AddCounter(metrics.Retries, 1, metrics.L("service", "up"), metrics.L("api", api))
+	}
+}
+
+func recordPartUpload(upHost string, start time.Time) {
+	if metrics.Enabled() {
+		metrics.Observe(metrics.PartUploadDuration, time.Since(start).Seconds(), metrics.L("host", upHost))
+	}
+}
+
+// recordHostFailure 记录域名失败次数，以及域名因此从可用变为被屏蔽的次数
+func recordHostFailure(hostName string, wasValid, isValid bool) {
+	metrics.AddCounter(metrics.HostFailures, 1, metrics.L("host", hostName))
+	if wasValid && !isValid {
+		metrics.AddCounter(metrics.HostBlacklisted, 1, metrics.L("host", hostName))
+	}
+}
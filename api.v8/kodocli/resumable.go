@@ -242,6 +242,7 @@ func (p Uploader) rput(
 				if tryTimes > 1 {
 					tryTimes--
 					elog.Info(xl.ReqId, "resumable.Put retrying ...")
+					recordRetry("mkblk")
 					goto lzRetry
 				}
 				elog.Warn(xl.ReqId, "resumable.Put", blkIdx, "failed:", err)
@@ -128,6 +128,7 @@ func (p Uploader) resumableBput(
 		if tryTimes > 1 {
 			tryTimes--
 			elog.Info(xl.ReqId, "ResumableBlockput retrying ...")
+			recordRetry("bput")
 			goto lzRetry
 		}
 		break
@@ -148,13 +149,11 @@ func (p Uploader) mkfile(
 	if hasKey {
 		url += "/key/" + encode(key)
 	}
-	for k, v := range extra.Params {
-		url += fmt.Sprintf("/%s/%s", k, encode(v))
+	for _, k := range sortedKeys(extra.Params) {
+		url += fmt.Sprintf("/%s/%s", k, encode(extra.Params[k]))
 	}
-	if extra.XMeta != nil {
-		for k, v := range extra.XMeta {
-			url += "/x-qn-meta-" + k + "/" + base64.URLEncoding.EncodeToString([]byte(v))
-		}
+	for _, k := range sortedKeys(extra.XMeta) {
+		url += "/x-qn-meta-" + k + "/" + base64.URLEncoding.EncodeToString([]byte(extra.XMeta[k]))
 	}
 	buf := make([]byte, 0, 176*len(extra.Progresses))
 	for _, prog := range extra.Progresses {
@@ -18,6 +18,7 @@ import (
 	"github.com/qiniupd/qiniu-go-sdk/api.v8/kodo"
 	"github.com/qiniupd/qiniu-go-sdk/api.v8/limit"
 	"github.com/qiniupd/qiniu-go-sdk/x/httputil.v1"
+	"github.com/qiniupd/qiniu-go-sdk/x/rpc.v7"
 	"github.com/qiniupd/qiniu-go-sdk/x/xlog.v8"
 )
 
@@ -295,14 +296,21 @@ func (s *sectionReader) Read(p []byte) (n int, err error) {
 }
 
 func (p Uploader) StreamUpload(ctx context.Context, ret interface{}, uptoken, key string, reader io.Reader, partNotify func(partIdx int, etag string)) error {
-	return p.streamUpload(ctx, ret, uptoken, key, true, reader, partNotify)
+	return p.streamUpload(ctx, ret, uptoken, key, true, reader, nil, partNotify)
+}
+
+// StreamUploadWithMultipart 与 StreamUpload 相同，mp 用于设置 MimeType、Metadata 等对象信息，其中的 Parts 会被忽略
+func (p Uploader) StreamUploadWithMultipart(ctx context.Context, ret interface{}, uptoken, key string, reader io.Reader,
+	mp *CompleteMultipart, partNotify func(partIdx int, etag string)) error {
+	return p.streamUpload(ctx, ret, uptoken, key, true, reader, mp, partNotify)
 }
 
 func (p Uploader) StreamUploadWithoutKey(ctx context.Context, ret interface{}, uptoken string, reader io.Reader, partNotify func(partIdx int, etag string)) error {
-	return p.streamUpload(ctx, ret, uptoken, "", false, reader, partNotify)
+	return p.streamUpload(ctx, ret, uptoken, "", false, reader, nil, partNotify)
 }
 
-func (p Uploader) streamUpload(ctx context.Context, ret interface{}, uptoken, key string, hasKey bool, reader io.Reader, partNotify func(partIdx int, etag string)) error {
+func (p Uploader) streamUpload(ctx context.Context, ret interface{}, uptoken, key string, hasKey bool, reader io.Reader,
+	mp *CompleteMultipart, partNotify func(partIdx int, etag string)) error {
 	policy, err := kodo.ParseUptoken(uptoken)
 	if err != nil {
 		return err
@@ -389,7 +397,11 @@ func (p Uploader) streamUpload(ctx context.Context, ret interface{}, uptoken, ke
 		}
 		return partUpErr
 	}
-	completeMultipart := CompleteMultipart{Parts: parts}
+	var completeMultipart CompleteMultipart
+	if mp != nil {
+		completeMultipart = *mp
+	}
+	completeMultipart.Parts = parts
 	completeMultipart.Sort()
 
 	return p.completePartsWithRetry(ctx, ret, bucket, key, hasKey, uploadId, &completeMultipart)
@@ -399,12 +411,14 @@ func (p Uploader) uploadPartWithRetry(ctx context.Context, bucket, key string, h
 	xl := xlog.NewWith(xlog.FromContextSafe(ctx).ReqId() + "." + fmt.Sprint(partNum))
 	tryTimes := uploadPartRetryTimes
 
-	for {
+	for attempt := 1; ; attempt++ {
 		upHost := p.chooseUpHost()
 		bodyReader, bodySize := getBody()
-		ret, err = p.uploadPart(ctx, upHost, bucket, key, hasKey, uploadId, partNum, bodyReader, bodySize)
+		start := time.Now()
+		ret, err = p.uploadPart(rpc.WithAttempt(ctx, attempt), upHost, bucket, key, hasKey, uploadId, partNum, bodyReader, bodySize)
 		if err == nil {
 			succeedHostName(upHost)
+			recordPartUpload(upHost, start)
 			break
 		} else {
 			if err == context.Canceled {
@@ -414,11 +428,13 @@ func (p Uploader) uploadPartWithRetry(ctx context.Context, bucket, key string, h
 			if code == 509 { // 因为流量受限失败，不减少重试次数
 				failHostName(upHost)
 				elog.Warn(xl.ReqId(), "uploadPartRetryLater:", partNum, err)
+				recordRetry("uploadPart")
 				time.Sleep(time.Second * time.Duration(rand.Intn(9)+1))
 			} else if tryTimes > 1 && (code == 406 || code/100 != 4) {
 				failHostName(upHost)
 				tryTimes--
 				elog.Warn(xl.ReqId(), "uploadPartRetry:", partNum, err)
+				recordRetry("uploadPart")
 				time.Sleep(time.Second * 3)
 			} else {
 				succeedHostName(upHost)
@@ -434,7 +450,7 @@ func (p Uploader) completePartsWithRetry(ctx context.Context, ret interface{}, b
 
 	for i := 0; i < completePartsRetryTimes; i++ {
 		upHost := p.chooseUpHost()
-		err = p.completeParts(ctx, upHost, ret, bucket, key, hasKey, uploadId, mp)
+		err = p.completeParts(rpc.WithAttempt(ctx, i+1), upHost, ret, bucket, key, hasKey, uploadId, mp)
 		if err == context.Canceled {
 			break
 		}
@@ -449,6 +465,7 @@ func (p Uploader) completePartsWithRetry(ctx context.Context, ret interface{}, b
 		} else {
 			failHostName(upHost)
 			elog.Error(xl.ReqId(), "completeParts:", err, code)
+			recordRetry("completeParts")
 			time.Sleep(time.Second * 3)
 		}
 	}
@@ -460,7 +477,7 @@ func (p Uploader) deletePartsWithRetry(ctx context.Context, bucket, key string,
 
 	for i := 0; i < deletePartsRetryTimes; i++ {
 		upHost := p.chooseUpHost()
-		err = p.deleteParts(ctx, upHost, bucket, key, hasKey, uploadId)
+		err = p.deleteParts(rpc.WithAttempt(ctx, i+1), upHost, bucket, key, hasKey, uploadId)
 		if err == context.Canceled {
 			break
 		}
@@ -471,6 +488,7 @@ func (p Uploader) deletePartsWithRetry(ctx context.Context, bucket, key string,
 		} else {
 			failHostName(upHost)
 			elog.Error(xl.ReqId(), "deleteParts:", err)
+			recordRetry("deleteParts")
 			time.Sleep(time.Second * 3)
 		}
 	}
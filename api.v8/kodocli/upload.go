@@ -14,6 +14,7 @@ import (
 	"net/textproto"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -123,8 +124,10 @@ func (p Uploader) put(
 
 	tryTimes := formUploadRetryTimes
 	xl := xlog.NewWith(xlog.FromContextSafe(ctx).ReqId())
+	attempt := 0
 
 lzRetry:
+	attempt++
 	var data io.Reader = io.NewSectionReader(dataReaderAt, 0, size)
 	if extra.OnProgress != nil {
 		data = &readerWithProgress{reader: data, fsize: size, onProgress: extra.OnProgress}
@@ -207,7 +210,7 @@ lzRetry:
 	if extra.Md5Trailer == nil {
 		req.ContentLength = bodyLen
 	}
-	resp, err := p.Conn.Do(ctx, req)
+	resp, err := p.Conn.Do(rpc.WithAttempt(ctx, attempt), req)
 	if err != nil {
 		if err == Canceled {
 			return
@@ -216,12 +219,14 @@ lzRetry:
 		if code == 509 {
 			failHostName(upHost)
 			elog.Warn(xl.ReqId(), "formUploadRetryLater:", err)
+			recordRetry("form")
 			time.Sleep(time.Second * time.Duration(rand.Intn(9)+1))
 			goto lzRetry
 		} else if tryTimes > 1 && (code == 406 || code/100 != 4) {
 			failHostName(upHost)
 			tryTimes--
 			elog.Warn(xl.ReqId(), "formUploadRetry:", err)
+			recordRetry("form")
 			time.Sleep(time.Second * 3)
 			goto lzRetry
 		}
@@ -284,21 +289,17 @@ func writeMultipart(
 	}
 
 	//extra.Params
-	if extra.Params != nil {
-		for k, v := range extra.Params {
-			if strings.HasPrefix(k, "x:") {
-				err = writer.WriteField(k, v)
-				if err != nil {
-					return
-				}
+	for _, k := range sortedKeys(extra.Params) {
+		if strings.HasPrefix(k, "x:") {
+			err = writer.WriteField(k, extra.Params[k])
+			if err != nil {
+				return
 			}
 		}
 	}
-	if extra.XMeta != nil {
-		for k, v := range extra.XMeta {
-			if err = writer.WriteField("x-qn-meta-"+k, v); err != nil {
-				return
-			}
+	for _, k := range sortedKeys(extra.XMeta) {
+		if err = writer.WriteField("x-qn-meta-"+k, extra.XMeta[k]); err != nil {
+			return
 		}
 	}
 	return err
@@ -378,11 +379,14 @@ func (p Uploader) put2(ctx Context, ret interface{}, uptoken, key string, data i
 		if extra.Crc32 != DontCheckCrc {
 			url += "/crc32/" + strconv.FormatInt(int64(extra.Crc32), 10)
 		}
-		for k, v := range extra.Params {
-			if strings.HasPrefix(k, "x:") && v != "" {
+		for _, k := range sortedKeys(extra.Params) {
+			if v := extra.Params[k]; strings.HasPrefix(k, "x:") && v != "" {
 				url += "/" + k + "/" + base64.URLEncoding.EncodeToString([]byte(v))
 			}
 		}
+		for _, k := range sortedKeys(extra.XMeta) {
+			url += "/x-qn-meta-" + k + "/" + base64.URLEncoding.EncodeToString([]byte(extra.XMeta[k]))
+		}
 	}
 
 	if key != "" {
@@ -410,3 +414,13 @@ func (p Uploader) put2(ctx Context, ret interface{}, uptoken, key string, data i
 	succeedHostName(upHost)
 	return nil
 }
+
+// sortedKeys 返回排序后的 key，使生成的上传 url 与 map 的遍历顺序无关
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
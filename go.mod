@@ -6,4 +6,5 @@ require (
 	github.com/fsnotify/fsnotify v1.4.9
 	github.com/pelletier/go-toml v1.8.1
 	github.com/stretchr/testify v1.6.1
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
@@ -15,7 +15,7 @@ import (
 func main() {
 	c := flag.String("c", "cfg.json", "config file")
 	flag.Parse()
-	config, err := operation.Load(*c)
+	config, err := operation.LoadConfig(*c)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -10,15 +10,20 @@ import (
 func main() {
 	cf := flag.String("c", "cfg.toml", "config")
 	f := flag.String("f", "file", "upload file")
+	k := flag.String("k", "", "object key, mapped from the file path by default")
 	flag.Parse()
 
-	x, err := operation.Load(*cf)
+	x, err := operation.LoadConfig(*cf)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
+	key, err := operation.NewKeyMapper(x).Key(*f, *k)
+	if err != nil {
+		log.Fatalln(err)
+	}
 	up := operation.NewUploader(x)
 
-	err = up.Upload(*f, *f)
+	err = up.Upload(*f, key)
 	log.Fatalln(err)
 }
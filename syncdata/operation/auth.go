@@ -0,0 +1,131 @@
+package operation
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/auth/qbox"
+)
+
+// serverAuth 校验访问上传服务的请求，支持 Bearer Token 和七牛 QBox/Qiniu 签名，
+// 两者都未配置时不做校验
+type serverAuth struct {
+	token string
+	mac   *qbox.Mac
+}
+
+func newServerAuth(cfg *Config) *serverAuth {
+	a := &serverAuth{token: cfg.AuthToken}
+	if cfg.AuthAk != "" && cfg.AuthSk != "" {
+		a.mac = &qbox.Mac{AccessKey: cfg.AuthAk, SecretKey: []byte(cfg.AuthSk)}
+	}
+	return a
+}
+
+func (a *serverAuth) enabled() bool {
+	return a.token != "" || a.mac != nil
+}
+
+// 签名请求允许的时间偏差，超出范围的请求视为重放
+const authTimeWindow = 15 * time.Minute
+
+// verify 检查请求的 Authorization 头，QBox 和 Qiniu 签名与 qbox.Transport 的签名方式一致。
+// QBox 签名不覆盖 JSON body，只接受 GET/HEAD 请求，且 query 中需要带上参与签名的过期时间 e（Unix 秒）；
+// Qiniu 签名需要带上 X-Qiniu-Date 头部，签名时间与服务端相差不能超过 authTimeWindow
+func (a *serverAuth) verify(req *http.Request) bool {
+	if !a.enabled() {
+		return true
+	}
+	auth := req.Header.Get("Authorization")
+	switch {
+	case a.token != "" && strings.HasPrefix(auth, "Bearer "):
+		token := strings.TrimPrefix(auth, "Bearer ")
+		return subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1
+	case a.mac != nil && strings.HasPrefix(auth, "QBox "):
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			return false
+		}
+		if !validDeadline(req.URL.Query().Get("e"), time.Now()) {
+			return false
+		}
+		token, err := a.mac.SignRequest(req, false)
+		if err != nil {
+			return false
+		}
+		return subtle.ConstantTimeCompare([]byte(auth), []byte("QBox "+token)) == 1
+	case a.mac != nil && strings.HasPrefix(auth, "Qiniu "):
+		if !validSignTime(req.Header.Get(qbox.XQiniuDate), time.Now()) {
+			return false
+		}
+		ok, err := a.mac.VerifyCallbackV2(req)
+		return err == nil && ok
+	}
+	return false
+}
+
+// validDeadline 检查过期时间 e 没有过期，并且不超过 now+authTimeWindow，避免签发长期有效的请求
+func validDeadline(e string, now time.Time) bool {
+	deadline, err := strconv.ParseInt(e, 10, 64)
+	if err != nil {
+		return false
+	}
+	return deadline >= now.Unix() && deadline <= now.Add(authTimeWindow).Unix()
+}
+
+// validSignTime 检查 X-Qiniu-Date 与 now 相差不超过 authTimeWindow
+func validSignTime(date string, now time.Time) bool {
+	t, err := time.Parse(qbox.XQiniuDateFormat, date)
+	if err != nil {
+		return false
+	}
+	d := now.Sub(t)
+	return d <= authTimeWindow && d >= -authTimeWindow
+}
+
+// pathAllowList 限制上传请求中的本地路径只能位于指定的根目录下，未配置根目录时不做限制
+type pathAllowList struct {
+	roots []string
+}
+
+func newPathAllowList(roots []string) (*pathAllowList, error) {
+	l := &pathAllowList{}
+	for _, root := range roots {
+		abs, err := resolvePath(root)
+		if err != nil {
+			return nil, err
+		}
+		l.roots = append(l.roots, abs)
+	}
+	return l, nil
+}
+
+// resolvePath 返回路径的绝对形式，并解析其中的符号链接，避免通过符号链接访问根目录以外的文件
+func resolvePath(p string) (string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+func (l *pathAllowList) allowed(p string) bool {
+	if len(l.roots) == 0 {
+		return true
+	}
+	abs, err := resolvePath(p)
+	if err != nil {
+		return false
+	}
+	for _, root := range l.roots {
+		rel, err := filepath.Rel(root, abs)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+			return true
+		}
+	}
+	return false
+}
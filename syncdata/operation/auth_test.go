@@ -0,0 +1,111 @@
+package operation
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/auth/qbox"
+)
+
+// tamperTransport 在签名之后替换请求 body，模拟被篡改的请求
+type tamperTransport struct {
+	body []byte
+}
+
+func (t tamperTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.body != nil {
+		req.Body = ioutil.NopCloser(bytes.NewReader(t.body))
+		req.ContentLength = int64(len(t.body))
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestServerAuthVerify(t *testing.T) {
+	a := newServerAuth(&Config{AuthToken: "secret", AuthAk: "ak", AuthSk: "sk"})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !a.verify(req) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	mac := qbox.NewMac("ak", "sk")
+	now := time.Now()
+	e := strconv.FormatInt(now.Add(time.Minute).Unix(), 10)
+	body := `{"path":"/data/a.car","key":"a.car"}`
+
+	cases := []struct {
+		name   string
+		method string
+		url    string
+		auth   string // 为空时使用 scheme 签名
+		scheme qbox.SignScheme
+		date   string
+		tamper string
+		code   int
+	}{
+		{name: "qiniu post", method: "POST", url: "/", scheme: qbox.SignQiniu, code: 200},
+		{name: "qiniu tampered body", method: "POST", url: "/", scheme: qbox.SignQiniu, tamper: `{"path":"/etc/passwd","key":"a.car"}`, code: 401},
+		{name: "qiniu stale date", method: "POST", url: "/", scheme: qbox.SignQiniu, date: now.Add(-time.Hour).UTC().Format(qbox.XQiniuDateFormat), code: 401},
+		{name: "qiniu future date", method: "POST", url: "/", scheme: qbox.SignQiniu, date: now.Add(time.Hour).UTC().Format(qbox.XQiniuDateFormat), code: 401},
+		{name: "qbox post", method: "POST", url: "/?e=" + e, scheme: qbox.SignQBox, code: 401},
+		{name: "qbox get", method: "GET", url: "/list?prefix=a&e=" + e, scheme: qbox.SignQBox, code: 200},
+		{name: "qbox get without deadline", method: "GET", url: "/list?prefix=a", scheme: qbox.SignQBox, code: 401},
+		{name: "qbox get expired", method: "GET", url: "/list?e=" + strconv.FormatInt(now.Add(-time.Minute).Unix(), 10), scheme: qbox.SignQBox, code: 401},
+		{name: "qbox get deadline too far", method: "GET", url: "/list?e=" + strconv.FormatInt(now.Add(time.Hour).Unix(), 10), scheme: qbox.SignQBox, code: 401},
+		{name: "bearer", method: "POST", url: "/", auth: "Bearer secret", code: 200},
+		{name: "bad bearer", method: "POST", url: "/", auth: "Bearer wrong", code: 401},
+		{name: "no auth", method: "GET", url: "/list", auth: "-", code: 401},
+	}
+	for _, c := range cases {
+		var reqBody []byte
+		if c.method == "POST" {
+			reqBody = []byte(body)
+		}
+		req, err := http.NewRequest(c.method, srv.URL+c.url, bytes.NewReader(reqBody))
+		if err != nil {
+			t.Fatal(c.name, err)
+		}
+		if reqBody != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if c.date != "" {
+			req.Header.Set(qbox.XQiniuDate, c.date)
+		}
+		var rt http.RoundTripper = tamperTransport{}
+		switch c.auth {
+		case "":
+			var tamper []byte
+			if c.tamper != "" {
+				tamper = []byte(c.tamper)
+			}
+			rt = qbox.NewTransport(mac, tamperTransport{body: tamper})
+			req = req.WithContext(qbox.WithSignScheme(context.Background(), c.scheme))
+		case "-":
+		default:
+			req.Header.Set("Authorization", c.auth)
+		}
+		resp, err := (&http.Client{Transport: rt}).Do(req)
+		if err != nil {
+			t.Fatal(c.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.code {
+			t.Errorf("%s: status %d, want %d", c.name, resp.StatusCode, c.code)
+		}
+	}
+}
+
+func TestServerAuthDisabled(t *testing.T) {
+	a := newServerAuth(&Config{})
+	req := httptest.NewRequest("POST", "/", nil)
+	if !a.verify(req) {
+		t.Fatal("auth without token or keys should accept all requests")
+	}
+}
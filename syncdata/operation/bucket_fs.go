@@ -0,0 +1,302 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/kodo"
+	"github.com/qiniupd/qiniu-go-sdk/x/httputil.v1"
+)
+
+// 以存储空间为后端的只读文件系统，对象 key 中的 '/' 被视为目录分隔符
+type BucketFileSystem struct {
+	lister     *Lister
+	downloader *Downloader
+}
+
+// 根据配置创建存储空间文件系统
+func NewBucketFileSystem(c *Config) *BucketFileSystem {
+	return &BucketFileSystem{
+		lister:     NewLister(c),
+		downloader: NewDownloader(c),
+	}
+}
+
+// 校验配置后创建存储空间文件系统，配置不合法时返回 *ConfigError
+func NewBucketFileSystemE(c *Config) (*BucketFileSystem, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return NewBucketFileSystem(c), nil
+}
+
+// 返回以指定存储空间为后端的文件系统
+func (b *BucketFileSystem) WithBucket(bucket string) *BucketFileSystem {
+	return &BucketFileSystem{
+		lister:     b.lister.WithBucket(bucket),
+		downloader: b.downloader.WithBucket(bucket),
+	}
+}
+
+// 根据环境变量创建存储空间文件系统
+func NewBucketFileSystemV2() *BucketFileSystem {
+	m := getManager()
+	if m == nil {
+		return nil
+	}
+	return m.NewBucketFileSystem()
+}
+
+var (
+	errIsDirectory  = errors.New("is a directory")
+	errNotDirectory = errors.New("not a directory")
+)
+
+// Open implements FileSystem. 如果 name 对应的对象存在则作为文件打开，
+// 否则如果存在以 name + "/" 为前缀的对象则作为目录打开。
+func (b *BucketFileSystem) Open(name string) (File, error) {
+	return b.open(context.Background(), name)
+}
+
+// open 打开 name，读取文件时 ctx 取消则不再等待归档存储对象解冻
+func (b *BucketFileSystem) open(ctx context.Context, name string) (File, error) {
+	key := strings.TrimPrefix(path.Clean("/"+name), "/")
+	if key == "" {
+		return &bucketDir{fs: b, info: newDirInfo("/", time.Time{})}, nil
+	}
+
+	entry, err := b.lister.Stat(key)
+	if err == nil {
+		return &bucketFile{
+			fs:   b,
+			ctx:  ctx,
+			key:  key,
+			info: newFileInfo(key, entry.Fsize, entry.PutTime, entry),
+		}, nil
+	} else if httputil.DetectCode(err) != 612 {
+		return nil, err
+	}
+
+	items, prefixes, err := b.lister.ListDirectory(key+"/", "/")
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 && len(prefixes) == 0 {
+		return nil, os.ErrNotExist
+	}
+	return &bucketDir{
+		fs:       b,
+		prefix:   key + "/",
+		info:     newDirInfo(key, time.Time{}),
+		items:    items,
+		prefixes: prefixes,
+		listed:   true,
+	}, nil
+}
+
+// bucketFileInfo implements os.FileInfo for objects and common prefixes.
+type bucketFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	isDir   bool
+	sys     interface{}
+}
+
+func newFileInfo(key string, size, putTime int64, sys interface{}) *bucketFileInfo {
+	return &bucketFileInfo{
+		name:    path.Base(key),
+		size:    size,
+		modTime: putTimeToTime(putTime),
+		sys:     sys,
+	}
+}
+
+func newDirInfo(prefix string, modTime time.Time) *bucketFileInfo {
+	return &bucketFileInfo{
+		name:    path.Base(strings.TrimSuffix(prefix, "/")),
+		modTime: modTime,
+		isDir:   true,
+	}
+}
+
+// putTime 以 100 纳秒为单位
+func putTimeToTime(putTime int64) time.Time {
+	if putTime == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, putTime*100)
+}
+
+func (fi *bucketFileInfo) Name() string       { return fi.name }
+func (fi *bucketFileInfo) Size() int64        { return fi.size }
+func (fi *bucketFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *bucketFileInfo) IsDir() bool        { return fi.isDir }
+func (fi *bucketFileInfo) Sys() interface{}   { return fi.sys }
+
+func (fi *bucketFileInfo) Mode() os.FileMode {
+	if fi.isDir {
+		return os.ModeDir | 0555
+	}
+	return 0444
+}
+
+// bucketFile is a File backed by ranged reads of a single object.
+type bucketFile struct {
+	fs     *BucketFileSystem
+	ctx    context.Context
+	key    string
+	info   *bucketFileInfo
+	offset int64
+	body   io.ReadCloser
+	closed bool
+}
+
+func (f *bucketFile) Read(p []byte) (int, error) {
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	if f.offset >= f.info.size {
+		return 0, io.EOF
+	}
+	if f.body == nil {
+		body, err := f.fs.downloader.DownloadRangeReaderWithContext(f.ctx, f.key, f.offset)
+		if err != nil {
+			return 0, err
+		}
+		f.body = body
+	}
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+	if err == io.EOF && f.offset < f.info.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (f *bucketFile) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = f.offset + offset
+	case io.SeekEnd:
+		abs = f.info.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("negative position")
+	}
+	if abs != f.offset && f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+	f.offset = abs
+	return abs, nil
+}
+
+func (f *bucketFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, errNotDirectory
+}
+
+func (f *bucketFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *bucketFile) Close() error {
+	if f.closed {
+		return os.ErrClosed
+	}
+	f.closed = true
+	if f.body != nil {
+		return f.body.Close()
+	}
+	return nil
+}
+
+// bucketDir is a File listing the objects and common prefixes under a prefix.
+type bucketDir struct {
+	fs       *BucketFileSystem
+	prefix   string
+	info     *bucketFileInfo
+	items    []kodo.ListItem
+	prefixes []string
+	listed   bool
+	entries  []os.FileInfo
+	pos      int
+}
+
+func (d *bucketDir) Read(p []byte) (int, error) {
+	return 0, errIsDirectory
+}
+
+func (d *bucketDir) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekStart {
+		d.pos = 0
+		return 0, nil
+	}
+	return 0, errIsDirectory
+}
+
+func (d *bucketDir) load() error {
+	if d.entries != nil {
+		return nil
+	}
+	if !d.listed {
+		items, prefixes, err := d.fs.lister.ListDirectory(d.prefix, "/")
+		if err != nil {
+			return err
+		}
+		d.items, d.prefixes, d.listed = items, prefixes, true
+	}
+	entries := make([]os.FileInfo, 0, len(d.items)+len(d.prefixes))
+	for _, prefix := range d.prefixes {
+		entries = append(entries, newDirInfo(prefix, time.Time{}))
+	}
+	for _, item := range d.items {
+		// 以 '/' 结尾的对象是目录占位符，不作为文件返回
+		if strings.HasSuffix(item.Key, "/") {
+			continue
+		}
+		entries = append(entries, newFileInfo(item.Key, item.Fsize, item.PutTime, item))
+	}
+	d.entries = entries
+	return nil
+}
+
+func (d *bucketDir) Readdir(count int) ([]os.FileInfo, error) {
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	rest := d.entries[d.pos:]
+	if count <= 0 {
+		d.pos = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(rest) {
+		count = len(rest)
+	}
+	d.pos += count
+	return rest[:count], nil
+}
+
+func (d *bucketDir) Stat() (os.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *bucketDir) Close() error {
+	return nil
+}
@@ -0,0 +1,70 @@
+//go:build go1.16
+// +build go1.16
+
+package operation
+
+import (
+	"io/fs"
+	"sort"
+)
+
+// 返回 io/fs 形式的存储空间文件系统视图，可用于 http.FS、fs.WalkDir 等
+func (b *BucketFileSystem) IOFS() fs.FS {
+	return ioFS{b}
+}
+
+type ioFS struct {
+	b *BucketFileSystem
+}
+
+func (f ioFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	file, err := f.b.Open(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if d, ok := file.(*bucketDir); ok {
+		return ioDir{d}, nil
+	}
+	return file, nil
+}
+
+func (f ioFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	file, err := f.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	d, ok := file.(ioDir)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDirectory}
+	}
+	entries, err := d.ReadDir(-1)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, err
+}
+
+func (f ioFS) Stat(name string) (fs.FileInfo, error) {
+	file, err := f.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return file.Stat()
+}
+
+// ioDir implements fs.ReadDirFile on top of bucketDir.
+type ioDir struct {
+	*bucketDir
+}
+
+func (d ioDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	infos, err := d.Readdir(n)
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	return entries, err
+}
@@ -0,0 +1,16 @@
+//go:build go1.16
+// +build go1.16
+
+package operation
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestBucketFileSystemIOFS(t *testing.T) {
+	fs, _ := newTestFileSystem(t)
+	if err := fstest.TestFS(fs.IOFS(), "top.txt", "dir/a.txt", "dir/sub/d.txt", "dir/sub2/e"); err != nil {
+		t.Fatal(err)
+	}
+}
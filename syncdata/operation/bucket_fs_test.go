@@ -0,0 +1,304 @@
+package operation
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/kodo"
+)
+
+// 所有对象的上传时间，以 100 纳秒为单位
+const fakePutTime = 16000000000000000
+
+// fakeBucket 模拟 rs、rsf 和 io 服务，对象保存在内存中，列举时每页最多返回 pageSize 个结果
+type fakeBucket struct {
+	mu       sync.Mutex
+	objects  map[string]string
+	pageSize int
+	lists    int
+	ranges   []string // 下载请求的 Range 头
+}
+
+func (b *fakeBucket) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case strings.HasPrefix(req.URL.Path, "/stat/"):
+		entry, _ := base64.URLEncoding.DecodeString(strings.TrimPrefix(req.URL.Path, "/stat/"))
+		key := string(entry[strings.Index(string(entry), ":")+1:])
+		data, ok := b.objects[key]
+		if !ok {
+			w.WriteHeader(612)
+			w.Write([]byte(`{"error":"no such file or directory"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(kodo.Entry{Hash: "h-" + key, Fsize: int64(len(data)), PutTime: fakePutTime})
+	case req.URL.Path == "/list":
+		b.lists++
+		b.list(w, req)
+	case strings.HasPrefix(req.URL.Path, "/getfile/"):
+		// /getfile/<ak>/<bucket>/<key>
+		key := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/getfile/"), "/", 3)[2]
+		data, ok := b.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		b.ranges = append(b.ranges, req.Header.Get("Range"))
+		w.Header().Set("Etag", `"h-`+key+`"`)
+		http.ServeContent(w, req, key, putTimeToTime(fakePutTime), strings.NewReader(data))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (b *fakeBucket) list(w http.ResponseWriter, req *http.Request) {
+	prefix, delimiter := req.FormValue("prefix"), req.FormValue("delimiter")
+	keys := make([]string, 0, len(b.objects))
+	for key := range b.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	type result struct {
+		item   *kodo.ListItem
+		prefix string
+	}
+	var results []result
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if i := strings.Index(key[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+			p := key[:len(prefix)+i+1]
+			if !seen[p] {
+				seen[p] = true
+				results = append(results, result{prefix: p})
+			}
+			continue
+		}
+		results = append(results, result{item: &kodo.ListItem{Key: key, Hash: "h-" + key, Fsize: int64(len(b.objects[key])), PutTime: fakePutTime}})
+	}
+
+	start, _ := strconv.Atoi(req.FormValue("marker"))
+	end := start + b.pageSize
+	if end > len(results) {
+		end = len(results)
+	}
+	ret := struct {
+		Marker   string          `json:"marker,omitempty"`
+		Items    []kodo.ListItem `json:"items"`
+		Prefixes []string        `json:"commonPrefixes,omitempty"`
+	}{}
+	for _, r := range results[start:end] {
+		if r.item != nil {
+			ret.Items = append(ret.Items, *r.item)
+		} else {
+			ret.Prefixes = append(ret.Prefixes, r.prefix)
+		}
+	}
+	if end < len(results) {
+		ret.Marker = strconv.Itoa(end)
+	}
+	json.NewEncoder(w).Encode(ret)
+}
+
+// newFakeBucketConfig 返回 rs、rsf 和 io 域名都指向 b 的配置
+func newFakeBucketConfig(t *testing.T, b *fakeBucket) *Config {
+	srv := httptest.NewServer(b)
+	t.Cleanup(srv.Close)
+	return &Config{
+		Ak: "ak", Sk: "sk", Bucket: "bucket",
+		RsHosts: []string{srv.URL}, RsfHosts: []string{srv.URL}, IoHosts: []string{srv.URL},
+	}
+}
+
+func newTestFileSystem(t *testing.T) (*BucketFileSystem, *fakeBucket) {
+	b := &fakeBucket{
+		pageSize: 2,
+		objects: map[string]string{
+			"top.txt":       "top",
+			"dir/":          "", // 目录占位符
+			"dir/a.txt":     "0123456789",
+			"dir/b.txt":     "b",
+			"dir/c.txt":     "c",
+			"dir/sub/d.txt": "d",
+			"dir/sub2/e":    "e",
+		},
+	}
+	return NewBucketFileSystem(newFakeBucketConfig(t, b)), b
+}
+
+func TestBucketFileSystemOpen(t *testing.T) {
+	fs, _ := newTestFileSystem(t)
+	cases := []struct {
+		name  string
+		isDir bool
+		size  int64
+		base  string
+		err   error
+	}{
+		{name: "/", isDir: true, base: "."},
+		{name: "/top.txt", size: 3, base: "top.txt"},
+		{name: "dir/a.txt", size: 10, base: "a.txt"},
+		{name: "/dir", isDir: true, base: "dir"},
+		{name: "/dir/sub/", isDir: true, base: "sub"},
+		{name: "/dir/../dir/b.txt", size: 1, base: "b.txt"},
+		{name: "/missing", err: os.ErrNotExist},
+		{name: "/dir/a", err: os.ErrNotExist},
+	}
+	for _, c := range cases {
+		f, err := fs.Open(c.name)
+		if c.err != nil {
+			if err != c.err {
+				t.Errorf("%s: error %v, want %v", c.name, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		info, _ := f.Stat()
+		if info.IsDir() != c.isDir || info.Size() != c.size || info.Name() != c.base {
+			t.Errorf("%s: got dir %v size %d name %q", c.name, info.IsDir(), info.Size(), info.Name())
+		}
+		if !c.isDir && !info.ModTime().Equal(time.Unix(0, fakePutTime*100)) {
+			t.Errorf("%s: unexpected mod time %v", c.name, info.ModTime())
+		}
+		f.Close()
+	}
+}
+
+func TestBucketFileSystemReaddir(t *testing.T) {
+	fs, b := newTestFileSystem(t)
+	d, err := fs.Open("/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	// dir 下有 2 个公共前缀、3 个文件和 1 个目录占位符，每页 2 个结果需要列举 3 次
+	if b.lists != 3 {
+		t.Fatal("unexpected list requests:", b.lists)
+	}
+
+	var names []string
+	for {
+		infos, err := d.Readdir(4)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(infos) > 4 {
+			t.Fatal("Readdir returned more entries than requested:", len(infos))
+		}
+		for _, info := range infos {
+			name := info.Name()
+			if info.IsDir() {
+				name += "/"
+			}
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "a.txt,b.txt,c.txt,sub/,sub2/" {
+		t.Fatal("unexpected entries:", names)
+	}
+
+	// 回到开头后可以重新读取全部目录项
+	if _, err = d.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if infos, err := d.Readdir(-1); err != nil || len(infos) != 5 {
+		t.Fatal("Readdir(-1) after rewind:", len(infos), err)
+	}
+	if _, err = d.Read(make([]byte, 1)); err != errIsDirectory {
+		t.Fatal("Read on directory:", err)
+	}
+
+	f, err := fs.Open("/top.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.Readdir(-1); err != errNotDirectory {
+		t.Fatal("Readdir on file:", err)
+	}
+}
+
+func TestBucketFileSystemSeekRead(t *testing.T) {
+	fs, b := newTestFileSystem(t)
+	f, err := fs.Open("/dir/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(n int) string {
+		p := make([]byte, n)
+		n, err := io.ReadFull(f, p)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			t.Fatal(err)
+		}
+		return string(p[:n])
+	}
+	steps := []struct {
+		offset int64
+		whence int
+		pos    int64
+		n      int
+		want   string
+	}{
+		{offset: 0, whence: io.SeekStart, pos: 0, n: 3, want: "012"},
+		{offset: 0, whence: io.SeekCurrent, pos: 3, n: 2, want: "34"},
+		{offset: 2, whence: io.SeekCurrent, pos: 7, n: 1, want: "7"},
+		{offset: 2, whence: io.SeekStart, pos: 2, n: 3, want: "234"},
+		{offset: -2, whence: io.SeekEnd, pos: 8, n: 5, want: "89"},
+	}
+	for i, s := range steps {
+		pos, err := f.Seek(s.offset, s.whence)
+		if err != nil || pos != s.pos {
+			t.Fatalf("step %d: Seek got %d, %v, want %d", i, pos, err, s.pos)
+		}
+		if got := read(s.n); got != s.want {
+			t.Fatalf("step %d: read %q, want %q", i, got, s.want)
+		}
+	}
+	// 连续读取复用同一个下载，移动位置后从新的位置发起 Range 请求
+	if strings.Join(b.ranges, ",") != ",bytes=7-,bytes=2-,bytes=8-" {
+		t.Fatal("unexpected range requests:", b.ranges)
+	}
+
+	if _, err = f.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatal("read at end:", err)
+	}
+	if _, err = f.Seek(-1, io.SeekStart); err == nil {
+		t.Fatal("negative position should be rejected")
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Read(make([]byte, 1)); err != os.ErrClosed {
+		t.Fatal("read after close:", err)
+	}
+
+	// 整个文件的内容
+	f, _ = fs.Open("/dir/a.txt")
+	defer f.Close()
+	if data, err := ioutil.ReadAll(f); err != nil || string(data) != "0123456789" {
+		t.Fatal("ReadAll:", string(data), err)
+	}
+}
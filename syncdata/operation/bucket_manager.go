@@ -0,0 +1,26 @@
+package operation
+
+import (
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/kodo"
+	"github.com/qiniupd/qiniu-go-sdk/x/metrics.v1"
+)
+
+// 根据配置创建存储空间管理客户端，用于创建存储空间、设置访问权限和绑定域名等。
+// 返回的 kodo.Client 只使用 UcHosts、RsHosts 和 ApiHosts 中的第一个域名，不做域名轮换和失败重试，
+// 为空时使用 kodo 的默认域名
+func NewBucketManager(c *Config) *kodo.Client {
+	cfg := kodo.Config{
+		Transport:   &metrics.Transport{Service: "uc"},
+		Credentials: newCredentials(c),
+	}
+	if len(c.UcHosts) > 0 {
+		cfg.UCHost = c.UcHosts[0]
+	}
+	if len(c.RsHosts) > 0 {
+		cfg.RSHost = c.RsHosts[0]
+	}
+	if len(c.ApiHosts) > 0 {
+		cfg.APIHost = c.ApiHosts[0]
+	}
+	return kodo.NewWithoutZone(&cfg)
+}
@@ -0,0 +1,20 @@
+package operation
+
+import "testing"
+
+func TestNewBucketManagerHosts(t *testing.T) {
+	c := NewBucketManager(&Config{
+		Ak: "ak", Sk: "sk",
+		UcHosts:  []string{"http://uc1.example.com", "http://uc2.example.com"},
+		RsHosts:  []string{"http://rs1.example.com"},
+		ApiHosts: []string{"http://api1.example.com"},
+	})
+	if c.UCHost != "http://uc1.example.com" || c.RSHost != "http://rs1.example.com" || c.APIHost != "http://api1.example.com" {
+		t.Fatal("unexpected hosts:", c.UCHost, c.RSHost, c.APIHost)
+	}
+
+	c = NewBucketManager(&Config{Ak: "ak", Sk: "sk"})
+	if c.UCHost != "http://uc.qbox.me" || c.APIHost != "http://api.qiniu.com" {
+		t.Fatal("unexpected default hosts:", c.UCHost, c.APIHost)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"os"
 	"sync"
 	"time"
+
+	"github.com/qiniupd/qiniu-go-sdk/x/metrics.v1"
 )
 
 var (
@@ -43,8 +45,15 @@ func isHostNameValid(hostName string) bool {
 }
 
 func failHostName(hostName string) {
-	hs, _ := hostsScores.LoadOrStore(hostName, newHostsScore())
-	hs.(*hostsScore).fail()
+	v, _ := hostsScores.LoadOrStore(hostName, newHostsScore())
+	hs := v.(*hostsScore)
+	if !metrics.Enabled() {
+		hs.fail()
+		return
+	}
+	wasValid := hs.isValid()
+	hs.fail()
+	recordHostFailure(hostName, wasValid, hs.isValid())
 }
 
 func succeedHostName(hostName string) {
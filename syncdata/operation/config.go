@@ -3,39 +3,88 @@ package operation
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
-	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/pelletier/go-toml"
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/auth/qbox"
 	"github.com/qiniupd/qiniu-go-sdk/x/log.v7"
+	"gopkg.in/yaml.v3"
 )
 
 // 配置文件
 type Config struct {
-	UpHosts          []string `json:"up_hosts" toml:"up_hosts"`
-	RsHosts          []string `json:"rs_hosts" toml:"rs_hosts"`
-	RsfHosts         []string `json:"rsf_hosts" toml:"rsf_hosts"`
-	Bucket           string   `json:"bucket" toml:"bucket"`
-	Ak               string   `json:"ak" toml:"ak"`
-	Sk               string   `json:"sk" toml:"sk"`
-	PartSize         int64    `json:"part" toml:"part"`
-	Addr             string   `json:"addr" toml:"addr"`
-	Delete           bool     `json:"delete" toml:"delete"`
-	UpConcurrency    int      `json:"up_concurrency" toml:"up_concurrency"`
-	BatchConcurrency int      `json:"batch_concurrency" toml:"batch_concurrency"`
-	BatchSize        int      `json:"batch_size" toml:"batch_size"`
-
-	DownPath string `json:"down_path" toml:"down_path"`
-	Sim      bool   `json:"sim" toml:"sim"`
-
-	IoHosts []string `json:"io_hosts" toml:"io_hosts"`
-	UcHosts []string `json:"uc_hosts" toml:"uc_hosts"`
+	UpHosts          []string `json:"up_hosts" toml:"up_hosts" yaml:"up_hosts"`
+	RsHosts          []string `json:"rs_hosts" toml:"rs_hosts" yaml:"rs_hosts"`
+	RsfHosts         []string `json:"rsf_hosts" toml:"rsf_hosts" yaml:"rsf_hosts"`
+	Bucket           string   `json:"bucket" toml:"bucket" yaml:"bucket"`
+	Ak               string   `json:"ak" toml:"ak" yaml:"ak"`
+	Sk               string   `json:"sk" toml:"sk" yaml:"sk"`
+	CredentialsFile  string   `json:"credentials_file" toml:"credentials_file" yaml:"credentials_file"` // 密钥文件，内容为 {"ak": "...", "sk": "..."}，设置后忽略 Ak/Sk 并定期重新读取
+	PartSize         int64    `json:"part" toml:"part" yaml:"part"`
+	Addr             string   `json:"addr" toml:"addr" yaml:"addr"`
+	Delete           bool     `json:"delete" toml:"delete" yaml:"delete"`
+	UpConcurrency    int      `json:"up_concurrency" toml:"up_concurrency" yaml:"up_concurrency"`
+	BatchConcurrency int      `json:"batch_concurrency" toml:"batch_concurrency" yaml:"batch_concurrency"`
+	BatchSize        int      `json:"batch_size" toml:"batch_size" yaml:"batch_size"`
+	JobWorkers       int      `json:"job_workers" toml:"job_workers" yaml:"job_workers"`          // 上传服务同时处理的任务数
+	JobQueueSize     int      `json:"job_queue_size" toml:"job_queue_size" yaml:"job_queue_size"` // 上传服务排队任务数上限
+	JournalPath      string   `json:"journal_path" toml:"journal_path" yaml:"journal_path"`       // 上传任务日志路径，为空则不记录，服务重启后未完成的任务会丢失
+
+	DownPath  string `json:"down_path" toml:"down_path" yaml:"down_path"`
+	DownCache bool   `json:"down_cache" toml:"down_cache" yaml:"down_cache"` // 是否将从存储空间读取的对象缓存到 DownPath 中
+	Sim       bool   `json:"sim" toml:"sim" yaml:"sim"`
+
+	// 归档存储对象的自动解冻，见 Downloader
+	AutoRestore    bool `json:"auto_restore" toml:"auto_restore" yaml:"auto_restore"`          // 下载未解冻的归档存储对象时自动解冻，等待解冻完成后再下载
+	RestoreDays    int  `json:"restore_days" toml:"restore_days" yaml:"restore_days"`          // 解冻后可以下载的天数，为 0 时为 1 天
+	RestoreTimeout int  `json:"restore_timeout" toml:"restore_timeout" yaml:"restore_timeout"` // 等待解冻完成的超时时间，单位秒，为 0 时为 6 小时
+
+	IoHosts  []string `json:"io_hosts" toml:"io_hosts" yaml:"io_hosts"`
+	UcHosts  []string `json:"uc_hosts" toml:"uc_hosts" yaml:"uc_hosts"`
+	ApiHosts []string `json:"api_hosts" toml:"api_hosts" yaml:"api_hosts"` // 只有 NewBucketManager 使用，为空时使用 http://api.qiniu.com
+
+	// 上传服务的访问控制，AuthToken 用于 Bearer 认证，AuthAk/AuthSk 用于 QBox/Qiniu 签名认证（QBox 签名只能用于 GET/HEAD 请求），都为空时不做认证
+	AuthToken    string   `json:"auth_token" toml:"auth_token" yaml:"auth_token"`
+	AuthAk       string   `json:"auth_ak" toml:"auth_ak" yaml:"auth_ak"`
+	AuthSk       string   `json:"auth_sk" toml:"auth_sk" yaml:"auth_sk"`
+	AllowedRoots []string `json:"allowed_roots" toml:"allowed_roots" yaml:"allowed_roots"` // 允许上传的本地根目录，为空时不限制
+	TLSCertFile  string   `json:"tls_cert_file" toml:"tls_cert_file" yaml:"tls_cert_file"`
+	TLSKeyFile   string   `json:"tls_key_file" toml:"tls_key_file" yaml:"tls_key_file"`
+
+	DisableUpload   bool `json:"disable_upload" toml:"disable_upload" yaml:"disable_upload"` // 同时关闭上传任务的查询和取消
+	DisableDownload bool `json:"disable_download" toml:"disable_download" yaml:"disable_download"`
+	DisableList     bool `json:"disable_list" toml:"disable_list" yaml:"disable_list"`
+	DisableStat     bool `json:"disable_stat" toml:"disable_stat" yaml:"disable_stat"`
+
+	Metrics bool `json:"metrics" toml:"metrics" yaml:"metrics"` // 是否开启 /metrics 接口，未设置全局指标记录器时会自动创建；已设置的记录器不是 http.Handler 时不开启
+
+	// 本地路径到对象 key 的映射规则，见 KeyMapper
+	KeyStripPrefixes []string `json:"key_strip_prefixes" toml:"key_strip_prefixes" yaml:"key_strip_prefixes"` // 从本地路径中去掉的根目录
+	KeyPrefix        string   `json:"key_prefix" toml:"key_prefix" yaml:"key_prefix"`                         // 对象 key 前缀，支持 {hostname}、{date} 和 {miner} 变量
+	KeyStrict        bool     `json:"key_strict" toml:"key_strict" yaml:"key_strict"`                         // 是否拒绝不在 KeyStripPrefixes 下的本地路径
+	MinerID          string   `json:"miner_id" toml:"miner_id" yaml:"miner_id"`
+
+	Credentials qbox.CredentialsProvider `json:"-" toml:"-" yaml:"-"` // 自定义密钥提供者，优先于 CredentialsFile 和 Ak/Sk
+}
+
+// 密钥文件的重新读取间隔
+const credentialsRefreshInterval = time.Minute
+
+// newCredentials 按 Credentials、CredentialsFile、Ak/Sk 的优先级创建密钥提供者
+func newCredentials(c *Config) qbox.CredentialsProvider {
+	switch {
+	case c.Credentials != nil:
+		return c.Credentials
+	case c.CredentialsFile != "":
+		return qbox.NewRefreshableProvider(qbox.NewFileProvider(c.CredentialsFile), credentialsRefreshInterval)
+	default:
+		return qbox.NewMac(c.Ak, c.Sk)
+	}
 }
 
 func dupStrings(s []string) []string {
@@ -47,106 +96,67 @@ func dupStrings(s []string) []string {
 	return to
 }
 
-// 加载配置文件
+// 加载配置文件，并校验配置是否合法，支持 .json、.toml、.yaml 和 .yml 格式
 func Load(file string) (*Config, error) {
 	var configuration Config
+	if err := loadFile(file, &configuration); err != nil {
+		return nil, err
+	}
+	if err := configuration.Validate(); err != nil {
+		return nil, err
+	}
+	return &configuration, nil
+}
+
+// loadFile 将配置文件的内容合并到 c 中，文件中没有出现的配置项保持不变
+func loadFile(file string, c *Config) error {
 	raw, err := ioutil.ReadFile(file)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	ext := path.Ext(file)
 	ext = strings.ToLower(ext)
-	if ext == ".json" {
-		err = json.Unmarshal(raw, &configuration)
-	} else if ext == ".toml" {
-		err = toml.Unmarshal(raw, &configuration)
-	} else {
-		return nil, errors.New("configuration format invalid!")
+	switch ext {
+	case ".json":
+		return json.Unmarshal(raw, c)
+	case ".toml":
+		return toml.Unmarshal(raw, c)
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(raw, c)
+	default:
+		return errors.New("configuration format invalid!")
 	}
-
-	return &configuration, err
 }
 
-var g_conf *Config
+var g_manager *ConfigManager
 var confLock sync.Mutex
 
-func getConf() *Config {
+// getManager 返回读取 QINIU 环境变量指定的配置文件，并使用 QINIU_* 环境变量覆盖其中配置项的全局配置管理器；
+// 未设置 QINIU 时只使用环境变量中的配置
+func getManager() *ConfigManager {
 	up := os.Getenv("QINIU")
-	if up == "" {
+	if up == "" && !hasEnvConfig() {
 		log.Warn("not set qiniu environment")
 		return nil
 	}
 	confLock.Lock()
 	defer confLock.Unlock()
-	if g_conf != nil {
-		return g_conf
+	if g_manager != nil {
+		return g_manager
 	}
-	c, err := Load(up)
+	m, err := NewConfigManager(up)
 	if err != nil {
 		log.Warn("load conf failed", up, err)
 		return nil
 	}
-	g_conf = c
-	watchConfig(up)
-	return c
+	g_manager = m
+	return m
 }
 
-func watchConfig(filename string) {
-	initWG := sync.WaitGroup{}
-	initWG.Add(1)
-	go func() {
-		watcher, err := fsnotify.NewWatcher()
-		if err != nil {
-			elog.Fatal(err)
-		}
-		defer watcher.Close()
-
-		configFile := filepath.Clean(filename)
-		configDir, _ := filepath.Split(configFile)
-		realConfigFile, _ := filepath.EvalSymlinks(filename)
-
-		eventsWG := sync.WaitGroup{}
-		eventsWG.Add(1)
-		go func() {
-			for {
-				select {
-				case event, ok := <-watcher.Events:
-					if !ok { // 'Events' channel is closed
-						eventsWG.Done()
-						return
-					}
-					currentConfigFile, _ := filepath.EvalSymlinks(filename)
-					// we only care about the config file with the following cases:
-					// 1 - if the config file was modified or created
-					// 2 - if the real path to the config file changed (eg: k8s ConfigMap replacement)
-					const writeOrCreateMask = fsnotify.Write | fsnotify.Create
-					if (filepath.Clean(event.Name) == configFile &&
-						event.Op&writeOrCreateMask != 0) ||
-						(currentConfigFile != "" && currentConfigFile != realConfigFile) {
-						realConfigFile = currentConfigFile
-						c, err := Load(realConfigFile)
-						fmt.Printf("re reading config file: error %v\n", err)
-						if err == nil {
-							g_conf = c
-						}
-					} else if filepath.Clean(event.Name) == configFile &&
-						event.Op&fsnotify.Remove&fsnotify.Remove != 0 {
-						eventsWG.Done()
-						return
-					}
-
-				case err, ok := <-watcher.Errors:
-					if ok { // 'Errors' channel is not closed
-						fmt.Printf("watcher error: %v\n", err)
-					}
-					eventsWG.Done()
-					return
-				}
-			}
-		}()
-		watcher.Add(configDir)
-		initWG.Done()   // done initializing the watch in this go routine, so the parent routine can move on...
-		eventsWG.Wait() // now, wait for event loop to end in this go-routine...
-	}()
-	initWG.Wait() // make sure that the go routine above fully ended before returning
+func getConf() *Config {
+	m := getManager()
+	if m == nil {
+		return nil
+	}
+	return m.Config()
 }
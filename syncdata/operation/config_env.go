@@ -0,0 +1,130 @@
+package operation
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 环境变量配置项的前缀，配置项名称为前缀加上大写的 json 字段名，例如 QINIU_AK、QINIU_UP_HOSTS
+const EnvPrefix = "QINIU_"
+
+// 配置选项，在配置文件和环境变量之后生效
+type ConfigOption func(*Config)
+
+// 返回带有默认值的配置
+func DefaultConfig() *Config {
+	return &Config{
+		PartSize:         4,
+		BatchConcurrency: 20,
+		BatchSize:        100,
+		JobWorkers:       defaultJobWorkers,
+		JobQueueSize:     defaultJobQueueSize,
+	}
+}
+
+// 按 默认值 -> 配置文件 -> 环境变量 -> opts 的顺序合并配置，后者覆盖前者，合并完成后校验配置。
+// file 为空时不读取配置文件
+func LoadConfig(file string, opts ...ConfigOption) (*Config, error) {
+	c := DefaultConfig()
+	if file != "" {
+		if err := loadFile(file, c); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.LoadEnv(); err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// 使用 QINIU_* 环境变量覆盖配置项，未设置的环境变量不影响对应的配置项。
+// 列表类配置项以逗号分隔，布尔类配置项使用 strconv.ParseBool 解析
+func (c *Config) LoadEnv() error {
+	return c.loadEnv(os.LookupEnv)
+}
+
+func (c *Config) loadEnv(lookup func(string) (string, bool)) error {
+	e := &ConfigError{}
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := envName(t.Field(i))
+		if name == "" {
+			continue
+		}
+		value, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setField(v.Field(i), value); err != nil {
+			e.add("%s: %v", name, err)
+		}
+	}
+	if len(e.Errors) > 0 {
+		return e
+	}
+	return nil
+}
+
+// hasEnvConfig 检查是否设置了任意 QINIU_* 配置项
+func hasEnvConfig() bool {
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if name := envName(t.Field(i)); name != "" {
+			if _, ok := os.LookupEnv(name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func envName(f reflect.StructField) string {
+	tag := strings.Split(f.Tag.Get("json"), ",")[0]
+	if tag == "" || tag == "-" {
+		return ""
+	}
+	return EnvPrefix + strings.ToUpper(tag)
+}
+
+func setField(f reflect.Value, value string) error {
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Slice:
+		if f.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", f.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		f.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", f.Type())
+	}
+	return nil
+}
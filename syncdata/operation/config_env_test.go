@@ -0,0 +1,69 @@
+package operation
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadEnv(t *testing.T) {
+	cases := []struct {
+		name string
+		env  map[string]string
+		want Config
+		err  string // 不为空时期望返回包含该内容的 *ConfigError
+	}{
+		{name: "unset keeps value", env: map[string]string{}, want: Config{Ak: "file-ak", PartSize: 8}},
+		{name: "string", env: map[string]string{"QINIU_AK": "env-ak"}, want: Config{Ak: "env-ak", PartSize: 8}},
+		{name: "empty string overrides", env: map[string]string{"QINIU_AK": ""}, want: Config{PartSize: 8}},
+		{name: "int", env: map[string]string{"QINIU_PART": "16", "QINIU_BATCH_SIZE": "500"}, want: Config{Ak: "file-ak", PartSize: 16, BatchSize: 500}},
+		{name: "bool", env: map[string]string{"QINIU_SIM": "true", "QINIU_DISABLE_LIST": "1"}, want: Config{Ak: "file-ak", PartSize: 8, Sim: true, DisableList: true}},
+		{name: "list", env: map[string]string{"QINIU_UP_HOSTS": "http://up1.example.com, http://up2.example.com,"},
+			want: Config{Ak: "file-ak", PartSize: 8, UpHosts: []string{"http://up1.example.com", "http://up2.example.com"}}},
+		{name: "bad int", env: map[string]string{"QINIU_PART": "x"}, err: "QINIU_PART"},
+		{name: "bad bool", env: map[string]string{"QINIU_SIM": "maybe", "QINIU_PART": "x"}, err: "QINIU_SIM"},
+		{name: "ignored field", env: map[string]string{"QINIU_CREDENTIALS": "x", "QINIU_-": "x"}, want: Config{Ak: "file-ak", PartSize: 8}},
+	}
+	for _, c := range cases {
+		cfg := Config{Ak: "file-ak", PartSize: 8}
+		err := cfg.loadEnv(func(name string) (string, bool) {
+			v, ok := c.env[name]
+			return v, ok
+		})
+		if c.err != "" {
+			var ce *ConfigError
+			if !errors.As(err, &ce) || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: error %v, want %q", c.name, err, c.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(cfg, c.want) {
+			t.Errorf("%s: got %+v, %v, want %+v", c.name, cfg, err, c.want)
+		}
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cfg.json")
+	content := `{"ak":"file-ak","sk":"file-sk","bucket":"file-bucket","batch_size":50,"up_hosts":["http://up.example.com"]}`
+	if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]string{"QINIU_BUCKET": "env-bucket", "QINIU_SK": "env-sk"} {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	c, err := LoadConfig(file, func(c *Config) { c.Sk = "opt-sk" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 默认值 -> 配置文件 -> 环境变量 -> opts
+	if c.PartSize != 4 || c.BatchSize != 50 || c.Ak != "file-ak" || c.Bucket != "env-bucket" || c.Sk != "opt-sk" {
+		t.Fatalf("unexpected config: %+v", c)
+	}
+}
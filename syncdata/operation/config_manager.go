@@ -0,0 +1,249 @@
+package operation
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/qiniupd/qiniu-go-sdk/x/kvlog.v1"
+)
+
+// 配置管理器，配置文件变化时重新加载并校验配置，校验通过后原子地替换当前配置并通知订阅者。
+// 通过 ConfigManager 创建的上传器、下载器和列举器会自动使用最新的配置
+type ConfigManager struct {
+	file    string
+	opts    []ConfigOption
+	current atomic.Value // *Config
+
+	lock        sync.Mutex // 保护 subscribers、generation 和 notifying
+	subscribers map[uint64]func(*Config)
+	nextID      uint64
+	generation  uint64 // 每次替换配置时加一
+	notifying   bool   // 是否有协程正在通知订阅者
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// 创建配置管理器，配置的合并顺序与 LoadConfig 相同；file 不为空时监听该文件的变化，
+// 不再使用时需要调用 Stop
+func NewConfigManager(file string, opts ...ConfigOption) (*ConfigManager, error) {
+	c, err := LoadConfig(file, opts...)
+	if err != nil {
+		return nil, err
+	}
+	m := &ConfigManager{
+		file:        file,
+		opts:        opts,
+		subscribers: make(map[uint64]func(*Config)),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	m.current.Store(c)
+	if file == "" {
+		close(m.done)
+		return m, nil
+	}
+	if err = m.watch(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// 返回当前配置，调用方不能修改返回的配置
+func (m *ConfigManager) Config() *Config {
+	return m.current.Load().(*Config)
+}
+
+// 订阅配置变化，配置替换后会按订阅顺序依次调用 fn，返回的函数用于取消订阅。
+// 调用 fn 时不持有锁，fn 中可以调用 Update 或 Reload；通知过程中配置再次被替换时，
+// 尚未收到旧配置的订阅者只会收到最新的配置，每个订阅者收到的配置总是按替换顺序排列
+func (m *ConfigManager) Subscribe(fn func(*Config)) (unsubscribe func()) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	id := m.nextID
+	m.nextID++
+	m.subscribers[id] = fn
+	return func() {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		delete(m.subscribers, id)
+	}
+}
+
+// 重新加载配置，新配置不合法时保留当前配置并返回错误
+func (m *ConfigManager) Reload() error {
+	c, err := LoadConfig(m.file, m.opts...)
+	if err != nil {
+		return err
+	}
+	return m.Update(c)
+}
+
+// 校验并替换当前配置，替换成功后通知订阅者。
+// 其他协程正在通知订阅者时（包括在订阅者中调用 Update），由该协程通知新的配置，Update 直接返回
+func (m *ConfigManager) Update(c *Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	m.lock.Lock()
+	m.current.Store(c)
+	m.generation++
+	if m.notifying {
+		m.lock.Unlock()
+		return nil
+	}
+	m.notifying = true
+	m.lock.Unlock()
+
+	m.notify()
+	return nil
+}
+
+// notify 不持有锁地按订阅顺序通知订阅者，通知过程中配置被替换时放弃旧配置，从头通知最新的配置
+func (m *ConfigManager) notify() {
+	m.lock.Lock()
+	for {
+		gen, c := m.generation, m.Config()
+		ids := make([]uint64, 0, len(m.subscribers))
+		for id := range m.subscribers {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		fns := make([]func(*Config), 0, len(ids))
+		for _, id := range ids {
+			fns = append(fns, m.subscribers[id])
+		}
+		m.lock.Unlock()
+
+		for _, fn := range fns {
+			if m.replaced(gen) {
+				break
+			}
+			fn(c)
+		}
+
+		m.lock.Lock()
+		if m.generation == gen {
+			m.notifying = false
+			m.lock.Unlock()
+			return
+		}
+	}
+}
+
+// replaced 返回 gen 之后配置是否又被替换过
+func (m *ConfigManager) replaced(gen uint64) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.generation != gen
+}
+
+// 停止监听配置文件，可以重复调用
+func (m *ConfigManager) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+	<-m.done
+}
+
+// watch 监听配置文件所在目录，以便处理文件被替换或符号链接指向变化（例如 k8s ConfigMap 更新）的情况
+func (m *ConfigManager) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	configFile := filepath.Clean(m.file)
+	configDir, _ := filepath.Split(configFile)
+	if err = watcher.Add(configDir); err != nil {
+		watcher.Close()
+		return err
+	}
+	realConfigFile, _ := filepath.EvalSymlinks(m.file)
+
+	go func() {
+		defer close(m.done)
+		defer watcher.Close()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case event, ok := <-watcher.Events:
+				if !ok { // 'Events' channel is closed
+					return
+				}
+				currentConfigFile, _ := filepath.EvalSymlinks(m.file)
+				// we only care about the config file with the following cases:
+				// 1 - if the config file was modified or created
+				// 2 - if the real path to the config file changed (eg: k8s ConfigMap replacement)
+				const writeOrCreateMask = fsnotify.Write | fsnotify.Create
+				if (filepath.Clean(event.Name) == configFile &&
+					event.Op&writeOrCreateMask != 0) ||
+					(currentConfigFile != "" && currentConfigFile != realConfigFile) {
+					realConfigFile = currentConfigFile
+					if err := m.Reload(); err != nil {
+						logger.Warn(context.Background(), "reload config failed", kvlog.F("file", m.file), kvlog.F("error", err))
+					} else {
+						logger.Info(context.Background(), "config reloaded", kvlog.F("file", m.file))
+					}
+				} else if filepath.Clean(event.Name) == configFile &&
+					event.Op&fsnotify.Remove != 0 {
+					return
+				}
+
+			case err, ok := <-watcher.Errors:
+				if ok { // 'Errors' channel is not closed
+					logger.Error(context.Background(), "watch config failed", kvlog.F("file", m.file), kvlog.F("error", err))
+				}
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// configWithBucket 返回存储空间替换为 bucket 的配置副本，bucket 为空时返回 c
+func configWithBucket(c *Config, bucket string) *Config {
+	if bucket == "" {
+		return c
+	}
+	cc := *c
+	cc.Bucket = bucket
+	return &cc
+}
+
+// 创建使用最新配置的上传器
+func (m *ConfigManager) NewUploader() *Uploader {
+	c := m.Config()
+	up := NewUploader(c)
+	up.manager, up.conf = m, c
+	return up
+}
+
+// 创建使用最新配置的下载器
+func (m *ConfigManager) NewDownloader() *Downloader {
+	c := m.Config()
+	d := NewDownloader(c)
+	d.manager, d.conf = m, c
+	return d
+}
+
+// 创建使用最新配置的列举器
+func (m *ConfigManager) NewLister() *Lister {
+	c := m.Config()
+	l := NewLister(c)
+	l.manager, l.conf = m, c
+	return l
+}
+
+// 创建使用最新配置的存储空间文件系统
+func (m *ConfigManager) NewBucketFileSystem() *BucketFileSystem {
+	return &BucketFileSystem{
+		lister:     m.NewLister(),
+		downloader: m.NewDownloader(),
+	}
+}
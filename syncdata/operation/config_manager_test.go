@@ -0,0 +1,200 @@
+package operation
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, file, bucket string) {
+	content := `{"ak":"ak","sk":"sk","bucket":"` + bucket + `","io_hosts":["http://io.example.com"]}`
+	if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigManagerReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cfg.json")
+	writeConfigFile(t, file, "b1")
+	m, err := NewConfigManager(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Stop() // 停止监听，只测试显式调用 Reload 的行为
+
+	var order []string
+	var notified []*Config
+	m.Subscribe(func(c *Config) {
+		order = append(order, "first")
+		notified = append(notified, c)
+	})
+	unsubscribe := m.Subscribe(func(c *Config) { order = append(order, "second") })
+	d := m.NewDownloader()
+
+	cases := []struct {
+		name     string
+		content  string // 为空时写入 bucket 为 bucket 的合法配置
+		bucket   string
+		wantErr  bool
+		wantCall []string
+	}{
+		{name: "valid", bucket: "b2", wantCall: []string{"first", "second"}},
+		{name: "invalid keeps current", content: `{"ak":"ak","sk":"sk"}`, bucket: "b2", wantErr: true},
+		{name: "broken file keeps current", content: `{"ak":`, bucket: "b2", wantErr: true},
+		{name: "unsubscribed", bucket: "b3", wantCall: []string{"first"}},
+	}
+	for _, c := range cases {
+		if c.name == "unsubscribed" {
+			unsubscribe()
+		}
+		if c.content != "" {
+			if err := ioutil.WriteFile(file, []byte(c.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			writeConfigFile(t, file, c.bucket)
+		}
+		order, notified = nil, nil
+		err := m.Reload()
+		if (err != nil) != c.wantErr {
+			t.Fatalf("%s: Reload error %v", c.name, err)
+		}
+		if m.Config().Bucket != c.bucket {
+			t.Fatalf("%s: bucket %q, want %q", c.name, m.Config().Bucket, c.bucket)
+		}
+		if len(order) != len(c.wantCall) {
+			t.Fatalf("%s: subscribers called %v, want %v", c.name, order, c.wantCall)
+		}
+		for i := range order {
+			if order[i] != c.wantCall[i] {
+				t.Fatalf("%s: subscribers called %v, want %v", c.name, order, c.wantCall)
+			}
+		}
+		if len(notified) > 0 && notified[0] != m.Config() {
+			t.Fatalf("%s: subscriber should receive the new *Config", c.name)
+		}
+		// 通过 ConfigManager 创建的下载器使用最新的配置
+		if got := d.current().bucket; got != c.bucket {
+			t.Fatalf("%s: downloader bucket %q, want %q", c.name, got, c.bucket)
+		}
+	}
+}
+
+func TestConfigManagerWithBucket(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cfg.json")
+	write := func(bucket, host string) {
+		content := `{"ak":"ak","sk":"sk","bucket":"` + bucket + `","io_hosts":["` + host + `"],"up_hosts":["` + host + `"],"rs_hosts":["` + host + `"]}`
+		if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("b1", "http://h1")
+	m, err := NewConfigManager(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Stop()
+
+	up, d, l := m.NewUploader(), m.NewDownloader(), m.NewLister()
+	fixedUp, fixedD, fixedL := up.WithBucket("fixed"), d.WithBucket("fixed"), l.WithBucket("fixed")
+
+	for i, host := range []string{"http://h1", "http://h2"} {
+		if i > 0 {
+			write("b2", host)
+			if err = m.Reload(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		shared := m.Config()
+		// 重新加载后 WithBucket 指定的存储空间不变，其他配置随之更新
+		got := [][2]string{
+			{fixedUp.current().bucket, fixedUp.current().upHosts[0]},
+			{fixedD.current().bucket, fixedD.current().ioHosts[0]},
+			{fixedL.current().bucket, fixedL.current().rsHosts[0]},
+		}
+		for j, g := range got {
+			if g[0] != "fixed" || g[1] != host {
+				t.Errorf("round %d, client %d: got bucket %q host %q, want fixed %q", i, j, g[0], g[1], host)
+			}
+		}
+		// 指定的存储空间不影响共享的配置和其他客户端
+		if shared.Bucket != []string{"b1", "b2"}[i] || up.current().bucket != shared.Bucket ||
+			d.current().bucket != shared.Bucket || l.current().bucket != shared.Bucket {
+			t.Errorf("round %d: shared bucket %q, uploader %q, downloader %q, lister %q",
+				i, shared.Bucket, up.current().bucket, d.current().bucket, l.current().bucket)
+		}
+		if fixedD.current().conf != shared || fixedL.current().conf != shared || fixedUp.current().conf != shared {
+			t.Errorf("round %d: fixed bucket clients should track the shared config", i)
+		}
+	}
+}
+
+func TestConfigManagerWatch(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cfg.json")
+	writeConfigFile(t, file, "b1")
+	m, err := NewConfigManager(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+
+	ch := make(chan *Config, 10)
+	m.Subscribe(func(c *Config) { ch <- c })
+	writeConfigFile(t, file, "b2")
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case c := <-ch:
+			if c.Bucket == "b2" {
+				return
+			}
+		case <-timeout:
+			t.Fatal("config change was not reloaded, current bucket:", m.Config().Bucket)
+		}
+	}
+}
+
+func TestConfigManagerUpdateInSubscriber(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cfg.json")
+	writeConfigFile(t, file, "b1")
+	m, err := NewConfigManager(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Stop()
+
+	newConfig := func(bucket string) *Config {
+		c := *m.Config()
+		c.Bucket = bucket
+		return &c
+	}
+	var first, second []string
+	m.Subscribe(func(c *Config) {
+		first = append(first, c.Bucket)
+		// 订阅者中替换配置不会死锁，新的配置在本次通知结束后送达
+		if c.Bucket == "b2" {
+			if err := m.Update(newConfig("b3")); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	m.Subscribe(func(c *Config) { second = append(second, c.Bucket) })
+
+	done := make(chan error, 1)
+	go func() { done <- m.Update(newConfig("b2")) }()
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Update in subscriber deadlocked")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 第二个订阅者还没有收到 b2 时配置已经替换为 b3，只会收到 b3
+	if strings.Join(first, ",") != "b2,b3" || strings.Join(second, ",") != "b3" || m.Config().Bucket != "b3" {
+		t.Fatalf("first %v, second %v, current %s", first, second, m.Config().Bucket)
+	}
+}
@@ -1,35 +1,38 @@
 package operation
 
 import (
+	"context"
 	"net"
 	"net/http"
 	"strconv"
 	"strings"
+
+	"github.com/qiniupd/qiniu-go-sdk/x/kvlog.v1"
 )
 
 // Deprecated:
 func StartSimulateErrorServer(_ *Config) {
 	httpCode := ":10801"
 	errSocket := ":10082"
-	elog.Info("start error simulate")
+	logger.Info(context.Background(), "start error simulate")
 	go simulateConnectionError(errSocket)
 	simulateHttpCode(httpCode)
 }
 
 func handleConnection(conn net.Conn) {
-	elog.Info("close connection", conn.RemoteAddr())
+	logger.Info(context.Background(), "close connection", kvlog.F("remote", conn.RemoteAddr()))
 	conn.Close()
 }
 
 func simulateConnectionError(addr string) {
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
-		elog.Info("listen failed", err)
+		logger.Info(context.Background(), "listen failed", kvlog.F("addr", addr), kvlog.F("error", err))
 	}
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
-			elog.Info("accept error", err)
+			logger.Info(context.Background(), "accept error", kvlog.F("error", err))
 		}
 		go handleConnection(conn)
 	}
@@ -42,9 +45,9 @@ func (d *debug) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
 	seps := strings.Split(strings.TrimPrefix(path, "/"), "/")
 	code, err := strconv.ParseUint(seps[0], 10, 64)
-	elog.Info("request is", path)
+	logger.Info(r.Context(), "request is", kvlog.F("path", path))
 	if err != nil {
-		elog.Info("parse code failed", seps[0], err)
+		logger.Info(r.Context(), "parse code failed", kvlog.F("code", seps[0]), kvlog.F("error", err))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
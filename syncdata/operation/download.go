@@ -1,6 +1,7 @@
 package operation
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -14,21 +15,27 @@ import (
 	"time"
 
 	"github.com/qiniupd/qiniu-go-sdk/api.v7/auth/qbox"
+	"github.com/qiniupd/qiniu-go-sdk/x/httputil.v1"
+	"github.com/qiniupd/qiniu-go-sdk/x/kvlog.v1"
+	"github.com/qiniupd/qiniu-go-sdk/x/metrics.v1"
 	"github.com/qiniupd/qiniu-go-sdk/x/rpc.v7"
 )
 
 var downloadClient = &http.Client{
-	Transport: &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   1 * time.Second,
-			KeepAlive: 30 * time.Second,
-			DualStack: true,
-		}).DialContext,
-		MaxIdleConns:          100,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
+	Transport: &metrics.Transport{
+		Service: "io",
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   1 * time.Second,
+				KeepAlive: 30 * time.Second,
+				DualStack: true,
+			}).DialContext,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
 	},
 	Timeout: 10 * time.Minute,
 }
@@ -37,14 +44,18 @@ var downloadClient = &http.Client{
 type Downloader struct {
 	bucket      string
 	ioHosts     []string
-	credentials *qbox.Mac
+	credentials qbox.CredentialsProvider
 	queryer     *Queryer
+	restorer    *archiveRestorer // 开启 AutoRestore 时不为空
+
+	manager     *ConfigManager // 通过 ConfigManager 创建时不为空
+	conf        *Config
+	latest      atomic.Value // *Downloader
+	fixedBucket string       // 通过 WithBucket 指定的存储空间，配置变化后仍然使用该存储空间
 }
 
 // 根据配置创建下载器
 func NewDownloader(c *Config) *Downloader {
-	mac := qbox.NewMac(c.Ak, c.Sk)
-
 	var queryer *Queryer = nil
 
 	if len(c.UcHosts) > 0 {
@@ -54,55 +65,121 @@ func NewDownloader(c *Config) *Downloader {
 	downloader := Downloader{
 		bucket:      c.Bucket,
 		ioHosts:     dupStrings(c.IoHosts),
-		credentials: mac,
+		credentials: newCredentials(c),
 		queryer:     queryer,
+		restorer:    newArchiveRestorer(c),
 	}
 	shuffleHosts(downloader.ioHosts)
 	return &downloader
 }
 
-// 根据环境变量创建下载器
+// 校验配置后创建下载器，配置不合法时返回 *ConfigError
+func NewDownloaderE(c *Config) (*Downloader, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return NewDownloader(c), nil
+}
+
+// 返回从指定存储空间下载的下载器，与 d 共享其他配置
+func (d *Downloader) WithBucket(bucket string) *Downloader {
+	return &Downloader{
+		bucket:      bucket,
+		ioHosts:     d.ioHosts,
+		credentials: d.credentials,
+		queryer:     d.queryer.WithBucket(bucket),
+		restorer:    d.restorer.withBucket(bucket),
+		manager:     d.manager,
+		conf:        d.conf,
+		fixedBucket: bucket,
+	}
+}
+
+// 根据环境变量创建下载器，配置文件变化后自动使用新的配置
 func NewDownloaderV2() *Downloader {
-	c := getConf()
-	if c == nil {
+	m := getManager()
+	if m == nil {
 		return nil
 	}
-	return NewDownloader(c)
+	return m.NewDownloader()
 }
 
 // 下载指定对象到文件里
 func (d *Downloader) DownloadFile(key, path string) (f *os.File, err error) {
-	for i := 0; i < 3; i++ {
-		f, err = d.downloadFileInner(key, path)
-		if err == nil {
-			return
+	return d.DownloadFileWithContext(context.Background(), key, path)
+}
+
+// 下载指定对象到文件里，ctx 取消时不再等待归档存储对象解冻
+func (d *Downloader) DownloadFileWithContext(ctx context.Context, key, path string) (f *os.File, err error) {
+	d = d.current()
+	err = d.withRestore(ctx, key, func() (err error) {
+		for i := 0; i < 3; i++ {
+			if i > 0 {
+				recordRetry("io", "getfile")
+			}
+			f, err = d.downloadFileInner(key, path)
+			if err == nil {
+				return
+			}
 		}
-	}
+		return
+	})
 	return
 }
 
 // 下载指定对象到文件里
 func (d *Downloader) DownloadBytes(key string) (data []byte, err error) {
-	for i := 0; i < 3; i++ {
-		data, err = d.downloadBytesInner(key)
-		if err == nil {
-			break
+	d = d.current()
+	err = d.withRestore(context.Background(), key, func() (err error) {
+		for i := 0; i < 3; i++ {
+			if i > 0 {
+				recordRetry("io", "getfile")
+			}
+			data, err = d.downloadBytesInner(key)
+			if err == nil {
+				break
+			}
 		}
-	}
+		return
+	})
 	return
 }
 
 // 下载指定对象的指定范围到内存中
 func (d *Downloader) DownloadRangeBytes(key string, offset, size int64) (l int64, data []byte, err error) {
-	for i := 0; i < 3; i++ {
-		l, data, err = d.downloadRangeBytesInner(key, offset, size)
-		if err == nil {
-			break
+	d = d.current()
+	err = d.withRestore(context.Background(), key, func() (err error) {
+		for i := 0; i < 3; i++ {
+			if i > 0 {
+				recordRetry("io", "getfile")
+			}
+			l, data, err = d.downloadRangeBytesInner(key, offset, size)
+			if err == nil {
+				break
+			}
 		}
-	}
+		return
+	})
 	return
 }
 
+// withRestore 执行 download，返回 archiveFrozenCode 时如果对象是未解冻的归档存储对象，则解冻后重新执行一次；
+// 对象不是归档存储时返回 download 的原始错误
+func (d *Downloader) withRestore(ctx context.Context, key string, download func() error) error {
+	err := download()
+	if err == nil || d.restorer == nil || httputil.DetectCode(err) != archiveFrozenCode {
+		return err
+	}
+	restored, rerr := d.restorer.waitRestored(ctx, strings.TrimPrefix(key, "/"))
+	if rerr != nil {
+		return rerr
+	}
+	if !restored {
+		return err
+	}
+	return download()
+}
+
 // fileExists checks if a file exists and is not a directory before we
 // try using it to prevent further errors.
 func fileExists(filename string) bool {
@@ -115,7 +192,7 @@ func fileExists(filename string) bool {
 
 var curIoHostIndex uint32 = 0
 
-func (d *Downloader) nextHost() string {
+func (d *Downloader) nextHost() (string, error) {
 	ioHosts := d.ioHosts
 	if d.queryer != nil {
 		if hosts := d.queryer.QueryIoHosts(false); len(hosts) > 0 {
@@ -125,9 +202,9 @@ func (d *Downloader) nextHost() string {
 	}
 	switch len(ioHosts) {
 	case 0:
-		panic("No Io hosts is configured")
+		return "", errNoHosts("io")
 	case 1:
-		return ioHosts[0]
+		return ioHosts[0], nil
 	default:
 		var ioHost string
 		for i := 0; i <= len(ioHosts)*MaxFindHostsPrecent/100; i++ {
@@ -137,7 +214,7 @@ func (d *Downloader) nextHost() string {
 				break
 			}
 		}
-		return ioHost
+		return ioHost, nil
 	}
 }
 
@@ -153,10 +230,17 @@ func (d *Downloader) downloadFileInner(key, path string) (*os.File, error) {
 	if err != nil {
 		return nil, err
 	}
-	host := d.nextHost()
+	host, err := d.nextHost()
+	if err != nil {
+		return nil, err
+	}
 
-	fmt.Println("remote path", key)
-	url := fmt.Sprintf("%s/getfile/%s/%s/%s", host, d.credentials.AccessKey, d.bucket, key)
+	logger.Debug(context.Background(), "download file", kvlog.F("key", key), kvlog.F("path", path))
+	mac, err := d.credentials.Retrieve()
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/getfile/%s/%s/%s", host, mac.AccessKey, d.bucket, key)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		failHostName(host)
@@ -167,7 +251,7 @@ func (d *Downloader) downloadFileInner(key, path string) (*os.File, error) {
 	if length != 0 {
 		r := fmt.Sprintf("bytes=%d-", length)
 		req.Header.Set("Range", r)
-		fmt.Println("continue download")
+		logger.Debug(context.Background(), "continue download", kvlog.F("key", key), kvlog.F("offset", length))
 	}
 
 	response, err := downloadClient.Do(req)
@@ -182,7 +266,7 @@ func (d *Downloader) downloadFileInner(key, path string) (*os.File, error) {
 	}
 	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusPartialContent {
 		failHostName(host)
-		return nil, errors.New(response.Status)
+		return nil, httputil.NewError(response.StatusCode, response.Status)
 	}
 	succeedHostName(host)
 	ctLength := response.ContentLength
@@ -191,7 +275,7 @@ func (d *Downloader) downloadFileInner(key, path string) (*os.File, error) {
 		return nil, err
 	}
 	if ctLength != n {
-		elog.Warn("download length not equal", ctLength, n)
+		logger.Warn(context.Background(), "download length not equal", kvlog.F("key", key), kvlog.F("content_length", ctLength), kvlog.F("written", n))
 	}
 	f.Seek(0, io.SeekStart)
 	return f, nil
@@ -201,9 +285,16 @@ func (d *Downloader) downloadBytesInner(key string) ([]byte, error) {
 	if strings.HasPrefix(key, "/") {
 		key = strings.TrimPrefix(key, "/")
 	}
-	host := d.nextHost()
+	host, err := d.nextHost()
+	if err != nil {
+		return nil, err
+	}
 
-	url := fmt.Sprintf("%s/getfile/%s/%s/%s", host, d.credentials.AccessKey, d.bucket, key)
+	mac, err := d.credentials.Retrieve()
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/getfile/%s/%s/%s", host, mac.AccessKey, d.bucket, key)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
@@ -218,7 +309,7 @@ func (d *Downloader) downloadBytesInner(key string) ([]byte, error) {
 
 	if response.StatusCode != http.StatusOK {
 		failHostName(host)
-		return nil, errors.New(response.Status)
+		return nil, httputil.NewError(response.StatusCode, response.Status)
 	}
 	succeedHostName(host)
 	return ioutil.ReadAll(response.Body)
@@ -235,9 +326,16 @@ func (d *Downloader) downloadRangeBytesInner(key string, offset, size int64) (in
 	if strings.HasPrefix(key, "/") {
 		key = strings.TrimPrefix(key, "/")
 	}
-	host := d.nextHost()
+	host, err := d.nextHost()
+	if err != nil {
+		return -1, nil, err
+	}
 
-	url := fmt.Sprintf("%s/getfile/%s/%s/%s", host, d.credentials.AccessKey, d.bucket, key)
+	mac, err := d.credentials.Retrieve()
+	if err != nil {
+		return -1, nil, err
+	}
+	url := fmt.Sprintf("%s/getfile/%s/%s/%s", host, mac.AccessKey, d.bucket, key)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		failHostName(host)
@@ -255,7 +353,7 @@ func (d *Downloader) downloadRangeBytesInner(key string, offset, size int64) (in
 
 	if response.StatusCode != http.StatusPartialContent {
 		failHostName(host)
-		return -1, nil, errors.New(response.Status)
+		return -1, nil, httputil.NewError(response.StatusCode, response.Status)
 	}
 
 	rangeResponse := response.Header.Get("Content-Range")
@@ -278,6 +376,76 @@ func (d *Downloader) downloadRangeBytesInner(key string, offset, size int64) (in
 	return l, b, err
 }
 
+// 从指定偏移量开始以流的方式读取对象内容，调用方负责关闭返回的 Reader
+func (d *Downloader) DownloadRangeReader(key string, offset int64) (r io.ReadCloser, err error) {
+	return d.DownloadRangeReaderWithContext(context.Background(), key, offset)
+}
+
+// 从指定偏移量开始以流的方式读取对象内容，ctx 取消时不再等待归档存储对象解冻
+func (d *Downloader) DownloadRangeReaderWithContext(ctx context.Context, key string, offset int64) (r io.ReadCloser, err error) {
+	d = d.current()
+	err = d.withRestore(ctx, key, func() (err error) {
+		r, err = d.downloadRangeReaderInner(key, offset)
+		return
+	})
+	return
+}
+
+func (d *Downloader) downloadRangeReaderInner(key string, offset int64) (io.ReadCloser, error) {
+	if strings.HasPrefix(key, "/") {
+		key = strings.TrimPrefix(key, "/")
+	}
+	host, err := d.nextHost()
+	if err != nil {
+		return nil, err
+	}
+
+	mac, err := d.credentials.Retrieve()
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/getfile/%s/%s/%s", host, mac.AccessKey, d.bucket, key)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		failHostName(host)
+		return nil, err
+	}
+	req.Header.Set("Accept-Encoding", "")
+	req.Header.Set("User-Agent", rpc.UserAgent)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	response, err := downloadClient.Do(req)
+	if err != nil {
+		failHostName(host)
+		return nil, err
+	}
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		succeedHostName(host)
+		if offset > 0 {
+			// 服务端忽略了 Range 请求，跳过偏移量之前的数据
+			if _, err = io.CopyN(ioutil.Discard, response.Body, offset); err != nil && err != io.EOF {
+				response.Body.Close()
+				return nil, err
+			}
+		}
+		return response.Body, nil
+	case http.StatusPartialContent:
+		succeedHostName(host)
+		return response.Body, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		succeedHostName(host)
+		response.Body.Close()
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	default:
+		failHostName(host)
+		response.Body.Close()
+		return nil, httputil.NewError(response.StatusCode, response.Status)
+	}
+}
+
 func getTotalLength(crange string) (int64, error) {
 	cr := strings.Split(crange, "/")
 	if len(cr) != 2 {
@@ -286,3 +454,21 @@ func getTotalLength(crange string) (int64, error) {
 
 	return strconv.ParseInt(cr[1], 10, 64)
 }
+
+// current 返回使用最新配置的下载器，配置没有变化时返回自身
+func (d *Downloader) current() *Downloader {
+	if d.manager == nil {
+		return d
+	}
+	c := d.manager.Config()
+	if c == d.conf {
+		return d
+	}
+	if latest, ok := d.latest.Load().(*Downloader); ok && latest.conf == c {
+		return latest
+	}
+	latest := NewDownloader(configWithBucket(c, d.fixedBucket))
+	latest.conf = c
+	d.latest.Store(latest)
+	return latest
+}
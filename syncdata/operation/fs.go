@@ -22,6 +22,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/qiniupd/qiniu-go-sdk/x/kvlog.v1"
 )
 
 // A Dir implements FileSystem using the native file system restricted to a
@@ -111,7 +113,7 @@ func logf(r *http.Request, format string, args ...interface{}) {
 	if s != nil && s.ErrorLog != nil {
 		s.ErrorLog.Printf(format, args...)
 	} else {
-		elog.Info(fmt.Sprintf(format, args...))
+		logger.Info(r.Context(), fmt.Sprintf(format, args...))
 	}
 }
 
@@ -240,7 +242,7 @@ func serveContent(w http.ResponseWriter, r *http.Request, name string, modtime t
 			if err == errNoOverlap {
 				w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
 			}
-			fmt.Println("range parse failed", 2, rangeReq, err.Error())
+			logger.Debug(r.Context(), "parse range failed", kvlog.F("range", rangeReq), kvlog.F("error", err))
 			http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
 			return
 		}
@@ -266,7 +268,7 @@ func serveContent(w http.ResponseWriter, r *http.Request, name string, modtime t
 			// multipart responses."
 			ra := ranges[0]
 			if _, err := content.Seek(ra.start, io.SeekStart); err != nil {
-				fmt.Println("range failed", 1)
+				logger.Debug(r.Context(), "seek range failed", kvlog.F("start", ra.start), kvlog.F("error", err))
 				http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
 				return
 			}
@@ -789,7 +791,6 @@ func parseRange(s string, size int64) ([]httpRange, error) {
 			// range start relative to the end of the file.
 			i, err := strconv.ParseInt(end, 10, 64)
 			if err != nil {
-				fmt.Println("parsed failed i0", start)
 				return nil, errors.New("invalid range")
 			}
 			if i > size {
@@ -800,14 +801,12 @@ func parseRange(s string, size int64) ([]httpRange, error) {
 		} else {
 			i, err := strconv.ParseInt(start, 10, 64)
 			if err != nil || i < 0 {
-				fmt.Println("parsed failed i1", start)
 				return nil, errors.New("invalid range")
 			}
 			if i >= size {
 				// If the range begins after the size of the content,
 				// then it does not overlap.
 				noOverlap = true
-				println("overlap", i, size, start, end)
 				continue
 			}
 			r.start = i
@@ -0,0 +1,462 @@
+package operation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	q "github.com/qiniupd/qiniu-go-sdk/api.v8/kodocli"
+	"github.com/qiniupd/qiniu-go-sdk/x/kvlog.v1"
+)
+
+// 上传任务及文件的状态
+type JobState string
+
+const (
+	JobPending   JobState = "pending"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+	JobCanceled  JobState = "canceled"
+)
+
+// 上传任务中单个文件的状态
+type FileStatus struct {
+	Path     string   `json:"path"`
+	Key      string   `json:"key"`
+	State    JobState `json:"state"`
+	Size     int64    `json:"size"`
+	Uploaded int64    `json:"uploaded"`
+	Hash     string   `json:"hash,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// 上传任务的状态
+type JobStatus struct {
+	ID        string       `json:"id"`
+	State     JobState     `json:"state"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	Files     []FileStatus `json:"files"`
+}
+
+const (
+	defaultJobWorkers   = 4
+	defaultJobQueueSize = 1024
+	jobRetention        = 24 * time.Hour
+)
+
+var (
+	ErrJobQueueFull = errors.New("job queue is full")
+	ErrJobNotFound  = errors.New("job not found")
+
+	errJobManagerClosed = errors.New("job manager is closed")
+)
+
+type job struct {
+	reqs        []Req
+	ctx         context.Context
+	cancel      context.CancelFunc
+	status      JobStatus
+	interrupted bool // 因为服务关闭而被中断，未完成的任务保留在日志中，重启后继续上传
+}
+
+// jobManager 使用固定数量的工作协程处理上传任务，并记录每个任务的状态
+type jobManager struct {
+	up       *Uploader
+	del      bool
+	downPath string
+	sim      bool
+	keys     *KeyMapper
+	journal  *journal
+
+	queue  chan *job
+	quit   chan struct{}
+	wg     sync.WaitGroup
+	lock   sync.Mutex
+	jobs   map[string]*job
+	closed bool
+}
+
+// newJobManager 创建任务管理器，如果配置了 JournalPath 则会恢复日志中未完成的任务
+func newJobManager(cfg *Config, up *Uploader) (*jobManager, error) {
+	workers := cfg.JobWorkers
+	if workers <= 0 {
+		workers = defaultJobWorkers
+	}
+	queueSize := cfg.JobQueueSize
+	if queueSize <= 0 {
+		queueSize = defaultJobQueueSize
+	}
+	m := &jobManager{
+		up:       up,
+		del:      cfg.Delete,
+		downPath: cfg.DownPath,
+		sim:      cfg.Sim,
+		keys:     NewKeyMapper(cfg),
+		queue:    make(chan *job, queueSize),
+		quit:     make(chan struct{}),
+		jobs:     make(map[string]*job),
+	}
+	var replayed []*journaledJob
+	if cfg.JournalPath != "" {
+		var err error
+		if m.journal, replayed, err = openJournal(cfg.JournalPath); err != nil {
+			return nil, err
+		}
+	}
+	m.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go m.work()
+	}
+	if len(replayed) > 0 {
+		logger.Info(context.Background(), "replay jobs from journal", kvlog.F("jobs", len(replayed)))
+		m.wg.Add(1)
+		go m.replay(replayed)
+	}
+	return m, nil
+}
+
+// replay 将日志中未完成的任务重新放入队列，已经成功上传的文件不会再次上传
+func (m *jobManager) replay(replayed []*journaledJob) {
+	defer m.wg.Done()
+	for _, r := range replayed {
+		reqs, err := m.resolveKeys(r.reqs)
+		if err != nil {
+			logger.Error(context.Background(), "replay job failed", kvlog.F("job", r.id), kvlog.F("error", err))
+			m.journalFinish(r.id, JobFailed)
+			continue
+		}
+		j := m.newJob(r.id, reqs, r.createdAt)
+		for index, hash := range r.hashes {
+			if index >= 0 && index < len(j.status.Files) {
+				f := &j.status.Files[index]
+				f.State = JobSucceeded
+				f.Hash = hash
+			}
+		}
+		m.lock.Lock()
+		if m.closed {
+			m.lock.Unlock()
+			return
+		}
+		m.jobs[j.status.ID] = j
+		m.lock.Unlock()
+		select {
+		case m.queue <- j:
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+func (m *jobManager) newJob(id string, reqs []Req, createdAt time.Time) *job {
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		reqs:   reqs,
+		ctx:    ctx,
+		cancel: cancel,
+		status: JobStatus{
+			ID:        id,
+			State:     JobPending,
+			CreatedAt: createdAt,
+			UpdatedAt: time.Now(),
+			Files:     make([]FileStatus, len(reqs)),
+		},
+	}
+	for i, req := range reqs {
+		j.status.Files[i] = FileStatus{Path: req.Path, Key: req.Key, State: JobPending}
+	}
+	return j
+}
+
+func newJobID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// 提交上传任务，队列已满时返回 ErrJobQueueFull，无法得到合法的对象 key 时返回 ErrInvalidKey；
+// 配置了任务日志时，任务写入日志后才会被接受
+func (m *jobManager) submit(reqs []Req) (JobStatus, error) {
+	if m.isClosed() {
+		return JobStatus{}, errJobManagerClosed
+	}
+	reqs, err := m.resolveKeys(reqs)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	now := time.Now()
+	j := m.newJob(newJobID(), reqs, now)
+	if m.journal != nil {
+		err := m.journal.append(&journalRecord{Op: journalOpSubmit, Job: j.status.ID, Reqs: reqs, CreatedAt: now})
+		if err != nil {
+			j.cancel()
+			return JobStatus{}, err
+		}
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.closed {
+		j.cancel()
+		return JobStatus{}, errJobManagerClosed
+	}
+	m.prune(now)
+	select {
+	case m.queue <- j:
+		recordQueueDepth(len(m.queue))
+	default:
+		j.cancel()
+		m.journalFinish(j.status.ID, JobCanceled)
+		return JobStatus{}, ErrJobQueueFull
+	}
+	m.jobs[j.status.ID] = j
+	return j.snapshot(), nil
+}
+
+// 获取任务状态
+func (m *jobManager) get(id string) (JobStatus, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return JobStatus{}, ErrJobNotFound
+	}
+	return j.snapshot(), nil
+}
+
+// 取消任务，正在上传的文件会被中断，尚未开始的文件不再上传
+func (m *jobManager) cancel(id string) (JobStatus, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return JobStatus{}, ErrJobNotFound
+	}
+	j.cancel()
+	if j.status.State == JobPending {
+		j.finish(JobCanceled)
+	}
+	return j.snapshot(), nil
+}
+
+// prune 清理已结束且超过保留时间的任务，调用方需持有锁
+func (m *jobManager) prune(now time.Time) {
+	for id, j := range m.jobs {
+		if j.done() && now.Sub(j.status.UpdatedAt) > jobRetention {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// resolveKeys 按映射规则确定每个文件的对象 key，写入日志的请求中 key 已经确定，恢复任务时不会因为
+// key 前缀中的日期等变量变化而改变
+func (m *jobManager) resolveKeys(reqs []Req) ([]Req, error) {
+	resolved := make([]Req, len(reqs))
+	for i, req := range reqs {
+		key, err := m.keys.Key(req.Path, req.Key)
+		if err != nil {
+			return nil, err
+		}
+		req.Key = key
+		resolved[i] = req
+	}
+	return resolved, nil
+}
+
+func (m *jobManager) shouldDelete(req Req) bool {
+	if req.Delete == nil {
+		return m.del
+	}
+	return *req.Delete
+}
+
+// 关闭任务管理器：不再接受新任务，中断正在上传的任务并等待工作协程退出，最后关闭任务日志。
+// 被中断和尚未开始的任务不会在日志中记为结束，配置了 JournalPath 时重启后会继续上传
+func (m *jobManager) close() error {
+	m.lock.Lock()
+	if m.closed {
+		m.lock.Unlock()
+		return nil
+	}
+	m.closed = true
+	close(m.quit)
+	for _, j := range m.jobs {
+		if !j.done() && j.ctx.Err() == nil {
+			j.interrupted = true
+			j.cancel()
+		}
+	}
+	m.lock.Unlock()
+
+	m.wg.Wait()
+	if m.journal != nil {
+		return m.journal.close()
+	}
+	return nil
+}
+
+func (m *jobManager) isClosed() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.closed
+}
+
+func (m *jobManager) work() {
+	defer m.wg.Done()
+	for {
+		select {
+		case <-m.quit:
+			return
+		case j := <-m.queue:
+			recordQueueDepth(len(m.queue))
+			m.run(j)
+		}
+	}
+}
+
+func (m *jobManager) run(j *job) {
+	m.lock.Lock()
+	if j.ctx.Err() != nil {
+		j.finish(JobCanceled)
+		interrupted := j.interrupted
+		m.lock.Unlock()
+		recordJobFinished(JobCanceled)
+		if !interrupted {
+			m.journalFinish(j.status.ID, JobCanceled)
+		}
+		return
+	}
+	j.status.State = JobRunning
+	j.status.UpdatedAt = time.Now()
+	m.lock.Unlock()
+
+	for i, req := range j.reqs {
+		if j.ctx.Err() != nil {
+			break
+		}
+		if j.status.Files[i].State == JobSucceeded {
+			// 从日志恢复的任务中已经成功上传的文件，只需补上未完成的删除
+			if !m.sim && m.shouldDelete(req) {
+				os.Remove(req.Path)
+			}
+			continue
+		}
+		m.update(j, i, func(f *FileStatus) {
+			f.State = JobRunning
+			if info, err := os.Stat(req.Path); err == nil {
+				f.Size = info.Size()
+			}
+		})
+
+		var (
+			ret q.PutRet
+			err error
+		)
+		if m.sim {
+			err = os.Rename(req.Path, m.downPath+renameFile(req.Key))
+			logger.Info(j.ctx, "move", kvlog.F("path", req.Path), kvlog.F("to", m.downPath+renameFile(req.Key)), kvlog.F("error", err))
+		} else {
+			err = m.up.upload(j.ctx, req.Path, req.Key, &ret, func(uploaded int64) {
+				m.update(j, i, func(f *FileStatus) { f.Uploaded = uploaded })
+			})
+		}
+		if err == nil {
+			// 只有上传结果已经落盘后才删除本地文件，否则重启后无法确认文件是否已上传
+			recorded := true
+			if m.journal != nil {
+				jerr := m.journal.append(&journalRecord{Op: journalOpFile, Job: j.status.ID, Index: i, Hash: ret.Hash, State: JobSucceeded})
+				if jerr != nil {
+					logger.Error(j.ctx, "journal upload result failed", kvlog.F("job", j.status.ID), kvlog.F("path", req.Path), kvlog.F("error", jerr))
+					recorded = false
+				}
+			}
+			if recorded && !m.sim && m.shouldDelete(req) {
+				os.Remove(req.Path)
+			}
+		}
+
+		m.update(j, i, func(f *FileStatus) {
+			switch {
+			case err == nil:
+				f.State = JobSucceeded
+				f.Uploaded = f.Size
+				f.Hash = ret.Hash
+			case j.ctx.Err() != nil:
+				f.State = JobCanceled
+				f.Error = j.ctx.Err().Error()
+			default:
+				f.State = JobFailed
+				f.Error = err.Error()
+			}
+		})
+	}
+
+	m.lock.Lock()
+	state := JobSucceeded
+	for i := range j.status.Files {
+		f := &j.status.Files[i]
+		if f.State == JobFailed {
+			state = JobFailed
+		} else if (f.State == JobCanceled || f.State == JobPending) && state != JobFailed {
+			state = JobCanceled
+		}
+	}
+	j.finish(state)
+	interrupted := j.interrupted
+	m.lock.Unlock()
+	j.cancel()
+	recordJobFinished(state)
+	if !interrupted || state == JobSucceeded {
+		m.journalFinish(j.status.ID, state)
+	}
+}
+
+func (m *jobManager) journalFinish(id string, state JobState) {
+	if m.journal == nil {
+		return
+	}
+	if err := m.journal.append(&journalRecord{Op: journalOpFinish, Job: id, State: state}); err != nil {
+		logger.Error(context.Background(), "journal finish failed", kvlog.F("job", id), kvlog.F("error", err))
+	}
+}
+
+func (m *jobManager) update(j *job, i int, fn func(f *FileStatus)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	fn(&j.status.Files[i])
+	j.status.UpdatedAt = time.Now()
+}
+
+// finish 设置任务的最终状态，未开始上传的文件一并标记为已取消
+func (j *job) finish(state JobState) {
+	for i := range j.status.Files {
+		if j.status.Files[i].State == JobPending {
+			j.status.Files[i].State = JobCanceled
+		}
+	}
+	j.status.State = state
+	j.status.UpdatedAt = time.Now()
+}
+
+func (j *job) done() bool {
+	switch j.status.State {
+	case JobSucceeded, JobFailed, JobCanceled:
+		return true
+	}
+	return false
+}
+
+func (j *job) snapshot() JobStatus {
+	s := j.status
+	s.Files = make([]FileStatus, len(j.status.Files))
+	copy(s.Files, j.status.Files)
+	return s
+}
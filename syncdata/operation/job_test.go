@@ -0,0 +1,148 @@
+package operation
+
+import (
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newJobTestManager 创建只有一个工作协程、队列长度为 1 的任务管理器，
+// 上传服务对 key 以 block 开头的文件一直阻塞到请求被取消
+func newJobTestManager(t *testing.T) (*jobManager, string) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 表单上传的 key 以 /key/<base64> 的形式出现在路径末尾
+		i := strings.LastIndex(r.URL.Path, "/key/")
+		key, _ := base64.URLEncoding.DecodeString(r.URL.Path[i+len("/key/"):])
+		if strings.HasPrefix(string(key), "block") {
+			// 读完请求体后服务端才能感知到客户端断开连接
+			io.Copy(ioutil.Discard, r.Body)
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(`{"hash":"h","key":"` + string(key) + `"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	cfg := &Config{
+		Ak: "ak", Sk: "sk", Bucket: "b", UpHosts: []string{srv.URL},
+		JobWorkers: 1, JobQueueSize: 1, JournalPath: filepath.Join(dir, "journal"),
+	}
+	m, err := newJobManager(cfg, NewUploader(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.close() })
+	return m, dir
+}
+
+func newJobTestFile(t *testing.T, dir, name string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// waitJobState 等待任务进入指定状态
+func waitJobState(t *testing.T, m *jobManager, id string, state JobState) JobStatus {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s, err := m.get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.State == state {
+			return s
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is %s, want %s", id, s.State, state)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestJobManager(t *testing.T) {
+	m, dir := newJobTestManager(t)
+
+	a, err := m.submit([]Req{{Path: newJobTestFile(t, dir, "a"), Key: "block-a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitJobState(t, m, a.ID, JobRunning)
+	// 唯一的工作协程正在上传 a，b 留在队列中，队列已满时拒绝 c
+	b, err := m.submit([]Req{{Path: newJobTestFile(t, dir, "b"), Key: "b"}})
+	if err != nil || b.State != JobPending || len(b.Files) != 1 || b.Files[0].Key != "b" {
+		t.Fatalf("submit b: %+v, %v", b, err)
+	}
+	if _, err = m.submit([]Req{{Path: newJobTestFile(t, dir, "c"), Key: "c"}}); err != ErrJobQueueFull {
+		t.Fatal("expected ErrJobQueueFull, got", err)
+	}
+
+	cases := []struct {
+		name  string
+		id    string
+		state JobState
+		err   error
+	}{
+		{name: "unknown job", id: "unknown", err: ErrJobNotFound},
+		{name: "pending job", id: b.ID, state: JobCanceled},
+		{name: "running job", id: a.ID, state: JobRunning},
+	}
+	for _, c := range cases {
+		s, err := m.cancel(c.id)
+		if err != c.err || s.State != c.state {
+			t.Errorf("%s: cancel got %s, %v, want %s, %v", c.name, s.State, err, c.state, c.err)
+		}
+	}
+	// 正在上传的文件被中断
+	s := waitJobState(t, m, a.ID, JobCanceled)
+	if s.Files[0].State != JobCanceled {
+		t.Fatalf("unexpected file state: %+v", s.Files[0])
+	}
+
+	d, err := m.submit([]Req{{Path: newJobTestFile(t, dir, "d"), Key: "d"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = waitJobState(t, m, d.ID, JobSucceeded)
+	if s.Files[0].Hash != "h" || s.Files[0].Size != 4 || s.Files[0].Uploaded != 4 {
+		t.Fatalf("unexpected file status: %+v", s.Files[0])
+	}
+	if _, err = m.get(d.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJobManagerClose(t *testing.T) {
+	m, dir := newJobTestManager(t)
+
+	a, err := m.submit([]Req{{Path: newJobTestFile(t, dir, "a"), Key: "block-a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitJobState(t, m, a.ID, JobRunning)
+	if err = m.close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.submit([]Req{{Path: newJobTestFile(t, dir, "b"), Key: "b"}}); err != errJobManagerClosed {
+		t.Fatal("expected errJobManagerClosed, got", err)
+	}
+	// 关闭时被中断的任务没有记为结束，重启后会继续上传
+	pending, err := replayJournal(filepath.Join(dir, "journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].id != a.ID {
+		t.Fatalf("unexpected pending jobs: %+v", pending)
+	}
+	if err = m.close(); err != nil {
+		t.Fatal("close twice:", err)
+	}
+}
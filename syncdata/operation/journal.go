@@ -0,0 +1,233 @@
+package operation
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/qiniupd/qiniu-go-sdk/x/kvlog.v1"
+)
+
+const (
+	journalOpSubmit = "submit"
+	journalOpFile   = "file"
+	journalOpFinish = "finish"
+
+	// 记录结束的任务数达到该值时压缩日志，避免日志无限增长
+	journalCompactThreshold = 1024
+)
+
+// journalRecord 是预写日志中的一行记录
+type journalRecord struct {
+	Op        string    `json:"op"`
+	Job       string    `json:"job"`
+	Reqs      []Req     `json:"reqs,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	Index     int       `json:"index,omitempty"`
+	Hash      string    `json:"hash,omitempty"`
+	State     JobState  `json:"state,omitempty"`
+}
+
+// journaledJob 是从日志中恢复出的未完成任务
+type journaledJob struct {
+	id        string
+	reqs      []Req
+	createdAt time.Time
+	hashes    map[int]string // 已成功上传的文件序号及其 hash
+}
+
+// journal 是上传任务的预写日志，每条记录写入后都会 fsync，保证服务重启后可以恢复未完成的任务
+type journal struct {
+	lock         sync.Mutex
+	path         string
+	f            *os.File
+	finished     int // 上次压缩后记录结束的任务数
+	compactAfter int
+}
+
+// 打开预写日志，返回其中记录的未完成任务，并将日志压缩为仅包含这些任务的记录
+func openJournal(path string) (*journal, []*journaledJob, error) {
+	pending, err := replayJournal(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = compactJournal(path, pending); err != nil {
+		return nil, nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &journal{path: path, f: f, compactAfter: journalCompactThreshold}, pending, nil
+}
+
+func replayJournal(path string) ([]*journaledJob, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		jobs  = make(map[string]*journaledJob)
+		order []string
+	)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var rec journalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// 最后一条记录可能因为进程退出而只写了一半，忽略无法解析的记录
+			logger.Warn(context.Background(), "skip broken journal record", kvlog.F("path", path), kvlog.F("error", err))
+			continue
+		}
+		switch rec.Op {
+		case journalOpSubmit:
+			if _, ok := jobs[rec.Job]; !ok {
+				order = append(order, rec.Job)
+			}
+			jobs[rec.Job] = &journaledJob{
+				id:        rec.Job,
+				reqs:      rec.Reqs,
+				createdAt: rec.CreatedAt,
+				hashes:    make(map[int]string),
+			}
+		case journalOpFile:
+			if j, ok := jobs[rec.Job]; ok && rec.State == JobSucceeded {
+				j.hashes[rec.Index] = rec.Hash
+			}
+		case journalOpFinish:
+			delete(jobs, rec.Job)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	pending := make([]*journaledJob, 0, len(jobs))
+	for _, id := range order {
+		if j, ok := jobs[id]; ok {
+			pending = append(pending, j)
+		}
+	}
+	return pending, nil
+}
+
+// compactJournal 将未完成任务重新写入临时文件，再原子地替换原日志
+func compactJournal(path string, pending []*journaledJob) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	err = writeJournalRecords(w, pending)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir 将目录项的变化落盘，保证 rename 之后掉电不会丢失新的日志文件
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func writeJournalRecords(w *bufio.Writer, pending []*journaledJob) error {
+	enc := json.NewEncoder(w)
+	for _, j := range pending {
+		if err := enc.Encode(&journalRecord{Op: journalOpSubmit, Job: j.id, Reqs: j.reqs, CreatedAt: j.createdAt}); err != nil {
+			return err
+		}
+		for index, hash := range j.hashes {
+			if err := enc.Encode(&journalRecord{Op: journalOpFile, Job: j.id, Index: index, Hash: hash, State: JobSucceeded}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 追加一条记录并等待其落盘
+func (j *journal) append(rec *journalRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if _, err = j.f.Write(b); err != nil {
+		return err
+	}
+	if err = j.f.Sync(); err != nil {
+		return err
+	}
+	if rec.Op == journalOpFinish {
+		if j.finished++; j.finished >= j.compactAfter {
+			// 记录已经落盘，压缩失败不影响本次追加，下次记录结束时重试
+			if err = j.compact(); err != nil {
+				logger.Warn(context.Background(), "compact journal failed", kvlog.F("path", j.path), kvlog.F("error", err))
+			}
+		}
+	}
+	return nil
+}
+
+// compact 将日志压缩为仅包含未完成任务的记录，调用方需持有锁
+func (j *journal) compact() error {
+	pending, err := replayJournal(j.path)
+	if err != nil {
+		return err
+	}
+	if err = compactJournal(j.path, pending); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	j.f.Close()
+	j.f = f
+	j.finished = 0
+	return nil
+}
+
+// 关闭日志文件，之后追加记录会返回错误
+func (j *journal) close() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.f.Close()
+}
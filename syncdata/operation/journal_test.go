@@ -0,0 +1,143 @@
+package operation
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	journalSubmitA = `{"op":"submit","job":"a","reqs":[{"path":"/data/1","key":"1"},{"path":"/data/2","key":"2"}]}`
+	journalSubmitB = `{"op":"submit","job":"b","reqs":[{"path":"/data/3","key":"3"}]}`
+	journalFileA0  = `{"op":"file","job":"a","index":0,"hash":"h0","state":"succeeded"}`
+	journalFileA1  = `{"op":"file","job":"a","index":1,"state":"failed"}`
+	journalFinishB = `{"op":"finish","job":"b","state":"succeeded"}`
+)
+
+func TestJournalReplay(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		want    map[string]map[int]string // 未完成任务的 id 到已上传文件 hash 的映射
+	}{
+		{name: "empty", content: "", want: map[string]map[int]string{}},
+		{name: "finished job", content: lines(journalSubmitA, journalSubmitB, journalFinishB),
+			want: map[string]map[int]string{"a": {}}},
+		{name: "succeeded files only", content: lines(journalSubmitA, journalFileA0, journalFileA1),
+			want: map[string]map[int]string{"a": {0: "h0"}}},
+		{name: "truncated last record", content: lines(journalSubmitA, journalSubmitB) + journalFinishB[:20],
+			want: map[string]map[int]string{"a": {}, "b": {}}},
+		{name: "torn record in the middle", content: lines(journalSubmitA, journalFileA0[:15], journalSubmitB, journalFinishB),
+			want: map[string]map[int]string{"a": {}}},
+		{name: "file of unknown job", content: lines(journalFileA0, journalSubmitB),
+			want: map[string]map[int]string{"b": {}}},
+	}
+	for _, c := range cases {
+		path := filepath.Join(t.TempDir(), "journal")
+		if err := ioutil.WriteFile(path, []byte(c.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		pending, err := replayJournal(path)
+		if err != nil {
+			t.Fatal(c.name, err)
+		}
+		got := make(map[string]map[int]string)
+		for _, j := range pending {
+			got[j.id] = j.hashes
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: pending %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestJournalCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	content := lines(journalSubmitA, journalSubmitB, journalFileA0, journalFinishB) + journalFileA1[:10]
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	j, pending, err := openJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].id != "a" || len(pending[0].reqs) != 2 || pending[0].hashes[0] != "h0" {
+		t.Fatalf("unexpected pending jobs: %+v", pending)
+	}
+	// 压缩后只保留未完成任务的记录，半条记录也被丢弃，后续追加的记录不会与其拼接在一起
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(b), "\n"); n != 2 || strings.Contains(string(b), `"job":"b"`) {
+		t.Fatalf("unexpected compacted journal:\n%s", b)
+	}
+	if err = j.append(&journalRecord{Op: journalOpFile, Job: "a", Index: 1, Hash: "h1", State: JobSucceeded}); err != nil {
+		t.Fatal(err)
+	}
+	j.f.Close()
+
+	j, pending, err = openJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.f.Close()
+	if len(pending) != 1 || !reflect.DeepEqual(pending[0].hashes, map[int]string{0: "h0", 1: "h1"}) {
+		t.Fatalf("unexpected pending jobs after reopen: %+v", pending)
+	}
+	if _, err = os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatal("temporary compaction file should be renamed:", err)
+	}
+}
+
+func TestJournalCompactAfterFinished(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	j, _, err := openJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.close()
+	j.compactAfter = 2
+
+	records := []*journalRecord{
+		{Op: journalOpSubmit, Job: "a", Reqs: []Req{{Path: "/data/1", Key: "1"}}},
+		{Op: journalOpSubmit, Job: "b", Reqs: []Req{{Path: "/data/2", Key: "2"}}},
+		{Op: journalOpSubmit, Job: "c", Reqs: []Req{{Path: "/data/3", Key: "3"}}},
+		{Op: journalOpFinish, Job: "a", State: JobSucceeded},
+		{Op: journalOpFinish, Job: "b", State: JobFailed},
+	}
+	for _, rec := range records {
+		if err = j.append(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 结束的任务数达到阈值后只保留未完成任务 c 的记录，之后的记录追加到新的日志文件中
+	if err = j.append(&journalRecord{Op: journalOpFile, Job: "c", Index: 0, Hash: "h3", State: JobSucceeded}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(b), "\n"); n != 2 || strings.Contains(string(b), `"job":"a"`) || strings.Contains(string(b), `"job":"b"`) {
+		t.Fatalf("unexpected compacted journal:\n%s", b)
+	}
+	if j.finished != 0 {
+		t.Fatal("finished count should be reset after compaction:", j.finished)
+	}
+	pending, err := replayJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].id != "c" || pending[0].hashes[0] != "h3" {
+		t.Fatalf("unexpected pending jobs: %+v", pending)
+	}
+}
+
+func lines(records ...string) string {
+	return strings.Join(records, "\n") + "\n"
+}
@@ -0,0 +1,136 @@
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 无法根据本地路径得到合法的对象 key
+var ErrInvalidKey = errors.New("invalid object key")
+
+// 对象 key 前缀中支持的变量
+const (
+	keyVarHostname = "{hostname}"
+	keyVarDate     = "{date}" // 当前日期，格式为 20060102
+	keyVarMiner    = "{miner}"
+)
+
+// 本地路径到对象 key 的映射规则，上传服务、cmd/up 和模拟模式使用相同的规则
+type KeyMapper struct {
+	stripPrefixes []string
+	prefix        string
+	strict        bool
+	hostname      string
+	minerID       string
+	now           func() time.Time
+}
+
+// 根据配置创建 key 映射规则
+func NewKeyMapper(c *Config) *KeyMapper {
+	hostname, _ := os.Hostname()
+	m := &KeyMapper{
+		prefix:   c.KeyPrefix,
+		strict:   c.KeyStrict,
+		hostname: hostname,
+		minerID:  c.MinerID,
+		now:      time.Now,
+	}
+	for _, p := range c.KeyStripPrefixes {
+		m.stripPrefixes = append(m.stripPrefixes, filepath.ToSlash(absPath(p)))
+	}
+	return m
+}
+
+// 计算本地文件对应的对象 key。
+// 指定了 key 时原样使用，只拒绝空 key 和包含 '..' 的 key；否则将本地路径转为绝对路径，
+// 去掉最长匹配的 KeyStripPrefixes，再加上 KeyPrefix。
+// KeyStrict 为 true 时，不在任何 KeyStripPrefixes 下的路径会返回 ErrInvalidKey
+func (m *KeyMapper) Key(localPath, key string) (string, error) {
+	if key != "" {
+		return key, checkKey(key)
+	}
+	p := filepath.ToSlash(absPath(localPath))
+	rest, longest := "", -1
+	for _, prefix := range m.stripPrefixes {
+		if r, ok := trimPathPrefix(p, prefix); ok && len(prefix) > longest {
+			rest, longest = r, len(prefix)
+		}
+	}
+	if longest >= 0 {
+		p = rest
+	} else if m.strict {
+		return "", fmt.Errorf("%w: %s is not under any key_strip_prefixes", ErrInvalidKey, localPath)
+	}
+	return normalizeKey(m.expandPrefix() + strings.TrimPrefix(p, "/"))
+}
+
+func (m *KeyMapper) expandPrefix() string {
+	if m.prefix == "" {
+		return ""
+	}
+	return strings.NewReplacer(
+		keyVarHostname, m.hostname,
+		keyVarDate, m.now().Format("20060102"),
+		keyVarMiner, m.minerID,
+	).Replace(m.prefix)
+}
+
+// absPath 返回 p 的绝对路径，无法获取当前目录时退化为 filepath.Clean
+func absPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return filepath.Clean(p)
+}
+
+// trimPathPrefix 在 p 位于目录 prefix 之下时返回去掉 prefix 后的相对路径
+func trimPathPrefix(p, prefix string) (string, bool) {
+	if prefix == "/" {
+		return strings.TrimPrefix(p, "/"), strings.HasPrefix(p, "/")
+	}
+	if !strings.HasPrefix(p, prefix+"/") {
+		return "", false
+	}
+	return p[len(prefix)+1:], true
+}
+
+// normalizeKey 清理由本地路径生成的 key 中多余的 '/' 和 '.'，去掉开头和结尾的 '/'
+func normalizeKey(key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	k := strings.TrimPrefix(path.Clean("/"+key), "/")
+	if k == "" {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return k, nil
+}
+
+// checkKey 拒绝空 key 和包含 '..' 的 key
+func checkKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == ".." {
+			return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		}
+	}
+	return nil
+}
+
+// validateKeyPrefix 检查 KeyPrefix 中是否只使用了支持的变量
+func validateKeyPrefix(e *ConfigError, c *Config) {
+	rest := strings.NewReplacer(keyVarHostname, "", keyVarDate, "", keyVarMiner, "").Replace(c.KeyPrefix)
+	if strings.ContainsAny(rest, "{}") {
+		e.add("key_prefix %q contains unknown variables", c.KeyPrefix)
+	}
+	if strings.Contains(c.KeyPrefix, keyVarMiner) && c.MinerID == "" {
+		e.add("key_prefix uses %s but miner_id is not set", keyVarMiner)
+	}
+}
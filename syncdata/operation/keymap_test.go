@@ -0,0 +1,54 @@
+package operation
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKeyMapperKey(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := filepath.Dir(wd)
+
+	cases := []struct {
+		name      string
+		cfg       Config
+		localPath string
+		key       string
+		want      string // 为空时期望返回 ErrInvalidKey
+	}{
+		{name: "explicit key", localPath: "/data/a.car", key: "dir//a.car/", want: "dir//a.car/"},
+		{name: "explicit key with dot", localPath: "/data/a.car", key: "./a.car", want: "./a.car"},
+		{name: "explicit key with dotdot", localPath: "/data/a.car", key: "dir/../a.car"},
+		{name: "no strip prefix", localPath: "/data/sealed/a.car", want: "data/sealed/a.car"},
+		{name: "strip prefix", cfg: Config{KeyStripPrefixes: []string{"/data"}}, localPath: "/data/sealed/a.car", want: "sealed/a.car"},
+		{name: "longest strip prefix", cfg: Config{KeyStripPrefixes: []string{"/data", "/data/sealed/"}}, localPath: "/data/sealed/a.car", want: "a.car"},
+		{name: "strip prefix is not a path prefix", cfg: Config{KeyStripPrefixes: []string{"/data"}}, localPath: "/database/a.car", want: "database/a.car"},
+		{name: "relative path", cfg: Config{KeyStripPrefixes: []string{parent}}, localPath: "../operation/a.car", want: "operation/a.car"},
+		{name: "relative strip prefix", cfg: Config{KeyStripPrefixes: []string{".."}}, localPath: filepath.Join(parent, "data", "a.car"), want: "data/a.car"},
+		{name: "strict", cfg: Config{KeyStripPrefixes: []string{"/data"}, KeyStrict: true}, localPath: "/other/a.car"},
+		{name: "strict matched", cfg: Config{KeyStripPrefixes: []string{"/data"}, KeyStrict: true}, localPath: "/data/a.car", want: "a.car"},
+		{name: "root path", localPath: "/"},
+		{name: "variables", cfg: Config{KeyPrefix: "{hostname}/{date}/{miner}/", MinerID: "f01234", KeyStripPrefixes: []string{"/data"}}, localPath: "/data/a.car", want: "host1/20201019/f01234/a.car"},
+	}
+	for _, c := range cases {
+		m := NewKeyMapper(&c.cfg)
+		m.hostname = "host1"
+		m.now = func() time.Time { return time.Date(2020, 10, 19, 8, 0, 0, 0, time.UTC) }
+		got, err := m.Key(c.localPath, c.key)
+		if c.want == "" {
+			if !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("%s: got %q, %v, want ErrInvalidKey", c.name, got, err)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("%s: got %q, %v, want %q", c.name, got, err, c.want)
+		}
+	}
+}
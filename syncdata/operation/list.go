@@ -1,6 +1,7 @@
 package operation
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"sync"
@@ -8,6 +9,8 @@ import (
 
 	"github.com/qiniupd/qiniu-go-sdk/api.v7/auth/qbox"
 	"github.com/qiniupd/qiniu-go-sdk/api.v7/kodo"
+	"github.com/qiniupd/qiniu-go-sdk/x/kvlog.v1"
+	"github.com/qiniupd/qiniu-go-sdk/x/metrics.v1"
 )
 
 // 列举器
@@ -16,10 +19,15 @@ type Lister struct {
 	rsHosts          []string
 	upHosts          []string
 	rsfHosts         []string
-	credentials      *qbox.Mac
+	credentials      qbox.CredentialsProvider
 	queryer          *Queryer
 	batchSize        int
 	batchConcurrency int
+
+	manager     *ConfigManager // 通过 ConfigManager 创建时不为空
+	conf        *Config
+	latest      atomic.Value // *Lister
+	fixedBucket string       // 通过 WithBucket 指定的存储空间，配置变化后仍然使用该存储空间
 }
 
 // 文件元信息
@@ -29,11 +37,12 @@ type FileStat struct {
 }
 
 func (l *Lister) batchStat(r io.Reader) []*FileStat {
+	l = l.current()
 	j := json.NewDecoder(r)
 	var fl []string
 	err := j.Decode(&fl)
 	if err != nil {
-		elog.Error(err)
+		logger.Error(context.Background(), "decode stat request failed", kvlog.F("error", err))
 		return nil
 	}
 	return l.ListStat(fl)
@@ -41,7 +50,7 @@ func (l *Lister) batchStat(r io.Reader) []*FileStat {
 
 var curRsHostIndex uint32 = 0
 
-func (l *Lister) nextRsHost() string {
+func (l *Lister) nextRsHost() (string, error) {
 	rsHosts := l.rsHosts
 	if l.queryer != nil {
 		if hosts := l.queryer.QueryRsHosts(false); len(hosts) > 0 {
@@ -51,9 +60,9 @@ func (l *Lister) nextRsHost() string {
 	}
 	switch len(rsHosts) {
 	case 0:
-		panic("No Rs hosts is configured")
+		return "", errNoHosts("rs")
 	case 1:
-		return rsHosts[0]
+		return rsHosts[0], nil
 	default:
 		var rsHost string
 		for i := 0; i <= len(rsHosts)*MaxFindHostsPrecent/100; i++ {
@@ -63,13 +72,13 @@ func (l *Lister) nextRsHost() string {
 				break
 			}
 		}
-		return rsHost
+		return rsHost, nil
 	}
 }
 
 var curRsfHostIndex uint32 = 0
 
-func (l *Lister) nextRsfHost() string {
+func (l *Lister) nextRsfHost() (string, error) {
 	rsfHosts := l.rsfHosts
 	if l.queryer != nil {
 		if hosts := l.queryer.QueryRsfHosts(false); len(hosts) > 0 {
@@ -79,9 +88,9 @@ func (l *Lister) nextRsfHost() string {
 	}
 	switch len(rsfHosts) {
 	case 0:
-		panic("No Rsf hosts is configured")
+		return "", errNoHosts("rsf")
 	case 1:
-		return rsfHosts[0]
+		return rsfHosts[0], nil
 	default:
 		var rsfHost string
 		for i := 0; i <= len(rsfHosts)*MaxFindHostsPrecent/100; i++ {
@@ -91,24 +100,31 @@ func (l *Lister) nextRsfHost() string {
 				break
 			}
 		}
-		return rsfHost
+		return rsfHost, nil
 	}
 }
 
 // 重命名对象
 func (l *Lister) Rename(fromKey, toKey string) error {
-	host := l.nextRsHost()
+	l = l.current()
+	host, err := l.nextRsHost()
+	if err != nil {
+		return err
+	}
 	bucket := l.newBucket(host, "")
-	err := bucket.Move(nil, fromKey, toKey)
+	err = bucket.Move(nil, fromKey, toKey)
 	if err != nil {
 		failHostName(host)
-		elog.Info("rename retry 0", host, err)
-		host = l.nextRsHost()
+		logger.Info(context.Background(), "rename retry 0", kvlog.F("host", host), kvlog.F("error", err))
+		recordRetry("rs", "move")
+		if host, err = l.nextRsHost(); err != nil {
+			return err
+		}
 		bucket = l.newBucket(host, "")
 		err = bucket.Move(nil, fromKey, toKey)
 		if err != nil {
 			failHostName(host)
-			elog.Info("rename retry 1", host, err)
+			logger.Info(context.Background(), "rename retry 1", kvlog.F("host", host), kvlog.F("error", err))
 			return err
 		} else {
 			succeedHostName(host)
@@ -121,18 +137,25 @@ func (l *Lister) Rename(fromKey, toKey string) error {
 
 // 移动对象到指定存储空间的指定对象中
 func (l *Lister) MoveTo(fromKey, toBucket, toKey string) error {
-	host := l.nextRsHost()
+	l = l.current()
+	host, err := l.nextRsHost()
+	if err != nil {
+		return err
+	}
 	bucket := l.newBucket(host, "")
-	err := bucket.MoveEx(nil, fromKey, toBucket, toKey)
+	err = bucket.MoveEx(nil, fromKey, toBucket, toKey)
 	if err != nil {
 		failHostName(host)
-		elog.Info("move retry 0", host, err)
-		host = l.nextRsHost()
+		logger.Info(context.Background(), "move retry 0", kvlog.F("host", host), kvlog.F("error", err))
+		recordRetry("rs", "move")
+		if host, err = l.nextRsHost(); err != nil {
+			return err
+		}
 		bucket = l.newBucket(host, "")
 		err = bucket.MoveEx(nil, fromKey, toBucket, toKey)
 		if err != nil {
 			failHostName(host)
-			elog.Info("move retry 1", host, err)
+			logger.Info(context.Background(), "move retry 1", kvlog.F("host", host), kvlog.F("error", err))
 			return err
 		} else {
 			succeedHostName(host)
@@ -145,18 +168,25 @@ func (l *Lister) MoveTo(fromKey, toBucket, toKey string) error {
 
 // 复制对象到当前存储空间的指定对象中
 func (l *Lister) Copy(fromKey, toKey string) error {
-	host := l.nextRsHost()
+	l = l.current()
+	host, err := l.nextRsHost()
+	if err != nil {
+		return err
+	}
 	bucket := l.newBucket(host, "")
-	err := bucket.Copy(nil, fromKey, toKey)
+	err = bucket.Copy(nil, fromKey, toKey)
 	if err != nil {
 		failHostName(host)
-		elog.Info("copy retry 0", host, err)
-		host = l.nextRsHost()
+		logger.Info(context.Background(), "copy retry 0", kvlog.F("host", host), kvlog.F("error", err))
+		recordRetry("rs", "copy")
+		if host, err = l.nextRsHost(); err != nil {
+			return err
+		}
 		bucket = l.newBucket(host, "")
 		err = bucket.Copy(nil, fromKey, toKey)
 		if err != nil {
 			failHostName(host)
-			elog.Info("copy retry 1", host, err)
+			logger.Info(context.Background(), "copy retry 1", kvlog.F("host", host), kvlog.F("error", err))
 			return err
 		} else {
 			succeedHostName(host)
@@ -169,18 +199,25 @@ func (l *Lister) Copy(fromKey, toKey string) error {
 
 // 删除指定对象
 func (l *Lister) Delete(key string) error {
-	host := l.nextRsHost()
+	l = l.current()
+	host, err := l.nextRsHost()
+	if err != nil {
+		return err
+	}
 	bucket := l.newBucket(host, "")
-	err := bucket.Delete(nil, key)
+	err = bucket.Delete(nil, key)
 	if err != nil {
 		failHostName(host)
-		elog.Info("delete retry 0", host, err)
-		host = l.nextRsHost()
+		logger.Info(context.Background(), "delete retry 0", kvlog.F("host", host), kvlog.F("error", err))
+		recordRetry("rs", "delete")
+		if host, err = l.nextRsHost(); err != nil {
+			return err
+		}
 		bucket = l.newBucket(host, "")
 		err = bucket.Delete(nil, key)
 		if err != nil {
 			failHostName(host)
-			elog.Info("delete retry 1", host, err)
+			logger.Info(context.Background(), "delete retry 1", kvlog.F("host", host), kvlog.F("error", err))
 			return err
 		} else {
 			succeedHostName(host)
@@ -193,6 +230,7 @@ func (l *Lister) Delete(key string) error {
 
 // 获取指定对象列表的元信息
 func (l *Lister) ListStat(paths []string) []*FileStat {
+	l = l.current()
 	type PathWithIdx struct {
 		paths []string
 		index int
@@ -215,18 +253,26 @@ func (l *Lister) ListStat(paths []string) []*FileStat {
 		go func() {
 			defer wg.Done()
 			for pi := range c {
-				host := l.nextRsHost()
+				host, err := l.nextRsHost()
+				if err != nil {
+					lock.Lock()
+					finalErr = err
+					lock.Unlock()
+					return
+				}
 				bucket := l.newBucket(host, "")
 				r, err := bucket.BatchStat(nil, pi.paths...)
 				if err != nil {
 					failHostName(host)
-					elog.Info("batchStat retry 0", host, err)
-					host = l.nextRsHost()
-					bucket = l.newBucket(host, "")
-					r, err = bucket.BatchStat(nil, pi.paths...)
+					logger.Info(context.Background(), "batchStat retry 0", kvlog.F("host", host), kvlog.F("error", err))
+					recordRetry("rs", "batch")
+					if host, err = l.nextRsHost(); err == nil {
+						bucket = l.newBucket(host, "")
+						r, err = bucket.BatchStat(nil, pi.paths...)
+					}
 					if err != nil {
 						failHostName(host)
-						elog.Info("batchStat retry 1", host, err)
+						logger.Info(context.Background(), "batchStat retry 1", kvlog.F("host", host), kvlog.F("error", err))
 						lock.Lock()
 						finalErr = err
 						lock.Unlock()
@@ -241,7 +287,7 @@ func (l *Lister) ListStat(paths []string) []*FileStat {
 				for j, v := range r {
 					if v.Code != 200 {
 						stats[pi.index+j] = &FileStat{Name: pi.paths[j], Size: -1}
-						elog.Warn("stat bad file:", pi.paths[j], "with code:", v.Code)
+						logger.Warn(context.Background(), "stat bad file", kvlog.F("key", pi.paths[j]), kvlog.F("code", v.Code))
 					} else {
 						stats[pi.index+j] = &FileStat{Name: pi.paths[j], Size: v.Data.Fsize}
 					}
@@ -269,8 +315,17 @@ func (l *Lister) ListStat(paths []string) []*FileStat {
 
 // 根据前缀列举存储空间
 func (l *Lister) ListPrefix(prefix string) []string {
-	rsHost := l.nextRsHost()
-	rsfHost := l.nextRsfHost()
+	l = l.current()
+	rsHost, err := l.nextRsHost()
+	if err != nil {
+		logger.Warn(context.Background(), "ListPrefix failed", kvlog.F("error", err))
+		return []string{}
+	}
+	rsfHost, err := l.nextRsfHost()
+	if err != nil {
+		logger.Warn(context.Background(), "ListPrefix failed", kvlog.F("error", err))
+		return []string{}
+	}
 	bucket := l.newBucket(rsHost, rsfHost)
 	var files []string
 	marker := ""
@@ -278,13 +333,15 @@ func (l *Lister) ListPrefix(prefix string) []string {
 		r, _, out, err := bucket.List(nil, prefix, "", marker, 1000)
 		if err != nil && err != io.EOF {
 			failHostName(rsfHost)
-			elog.Info("ListPrefix retry 0", rsfHost, err)
-			rsfHost = l.nextRsfHost()
-			bucket = l.newBucket(rsHost, rsfHost)
-			r, _, out, err = bucket.List(nil, prefix, "", "", 1000)
+			logger.Info(context.Background(), "ListPrefix retry 0", kvlog.F("host", rsfHost), kvlog.F("error", err))
+			recordRetry("rsf", "list")
+			if rsfHost, err = l.nextRsfHost(); err == nil {
+				bucket = l.newBucket(rsHost, rsfHost)
+				r, _, out, err = bucket.List(nil, prefix, "", "", 1000)
+			}
 			if err != nil {
 				failHostName(rsfHost)
-				elog.Info("ListPrefix retry 1", rsfHost, err)
+				logger.Info(context.Background(), "ListPrefix retry 1", kvlog.F("host", rsfHost), kvlog.F("error", err))
 				return []string{}
 			} else {
 				succeedHostName(rsfHost)
@@ -292,7 +349,7 @@ func (l *Lister) ListPrefix(prefix string) []string {
 		} else {
 			succeedHostName(rsfHost)
 		}
-		elog.Info("list len", marker, len(r))
+		logger.Info(context.Background(), "list len", kvlog.F("marker", marker), kvlog.F("len", len(r)))
 		for _, v := range r {
 			files = append(files, v.Key)
 		}
@@ -305,10 +362,52 @@ func (l *Lister) ListPrefix(prefix string) []string {
 	return files
 }
 
+// 获取指定对象的元信息
+func (l *Lister) Stat(key string) (entry kodo.Entry, err error) {
+	err = l.current().rsCall("stat", func(bucket kodo.Bucket) (err error) {
+		entry, err = bucket.Stat(nil, key)
+		return
+	})
+	return
+}
+
+// 根据前缀和分隔符列举存储空间，返回对象列表和公共前缀列表
+func (l *Lister) ListDirectory(prefix, delimiter string) ([]kodo.ListItem, []string, error) {
+	l = l.current()
+	var (
+		items    []kodo.ListItem
+		prefixes []string
+		marker   = ""
+	)
+	for {
+		var (
+			r   []kodo.ListItem
+			p   []string
+			out string
+		)
+		err := l.rsfCall("list", func(bucket kodo.Bucket) (err error) {
+			r, p, out, err = bucket.List(nil, prefix, delimiter, marker, 1000)
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		items = append(items, r...)
+		prefixes = append(prefixes, p...)
+
+		if out == "" {
+			break
+		}
+		marker = out
+	}
+	return items, prefixes, nil
+}
+
 // 根据配置创建列举器
 func NewLister(c *Config) *Lister {
-	mac := qbox.NewMac(c.Ak, c.Sk)
-
 	var queryer *Queryer = nil
 
 	if len(c.UcHosts) > 0 {
@@ -320,7 +419,7 @@ func NewLister(c *Config) *Lister {
 		rsHosts:          dupStrings(c.RsHosts),
 		upHosts:          dupStrings(c.UpHosts),
 		rsfHosts:         dupStrings(c.RsfHosts),
-		credentials:      mac,
+		credentials:      newCredentials(c),
 		queryer:          queryer,
 		batchConcurrency: c.BatchConcurrency,
 		batchSize:        c.BatchSize,
@@ -337,23 +436,70 @@ func NewLister(c *Config) *Lister {
 	return &lister
 }
 
-// 根据环境变量创建列举器
+// 校验配置后创建列举器，配置不合法时返回 *ConfigError
+func NewListerE(c *Config) (*Lister, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return NewLister(c), nil
+}
+
+// 返回操作指定存储空间的列举器，与 l 共享其他配置
+func (l *Lister) WithBucket(bucket string) *Lister {
+	return &Lister{
+		bucket:           bucket,
+		rsHosts:          l.rsHosts,
+		upHosts:          l.upHosts,
+		rsfHosts:         l.rsfHosts,
+		credentials:      l.credentials,
+		queryer:          l.queryer.WithBucket(bucket),
+		batchSize:        l.batchSize,
+		batchConcurrency: l.batchConcurrency,
+		manager:          l.manager,
+		conf:             l.conf,
+		fixedBucket:      bucket,
+	}
+}
+
+// 根据环境变量创建列举器，配置文件变化后自动使用新的配置
 func NewListerV2() *Lister {
-	c := getConf()
-	if c == nil {
+	m := getManager()
+	if m == nil {
 		return nil
 	}
-	return NewLister(c)
+	return m.NewLister()
 }
 
 func (l *Lister) newBucket(host, rsfHost string) kodo.Bucket {
+	service := "rs"
+	if rsfHost != "" {
+		service = "rsf"
+	}
 	cfg := kodo.Config{
-		AccessKey: l.credentials.AccessKey,
-		SecretKey: string(l.credentials.SecretKey),
-		RSHost:    host,
-		RSFHost:   rsfHost,
-		UpHosts:   l.upHosts,
+		RSHost:      host,
+		RSFHost:     rsfHost,
+		UpHosts:     l.upHosts,
+		Transport:   &metrics.Transport{Service: service},
+		Credentials: l.credentials,
 	}
 	client := kodo.NewWithoutZone(&cfg)
 	return client.Bucket(l.bucket)
 }
+
+// current 返回使用最新配置的列举器，配置没有变化时返回自身
+func (l *Lister) current() *Lister {
+	if l.manager == nil {
+		return l
+	}
+	c := l.manager.Config()
+	if c == l.conf {
+		return l
+	}
+	if latest, ok := l.latest.Load().(*Lister); ok && latest.conf == c {
+		return latest
+	}
+	latest := NewLister(configWithBucket(c, l.fixedBucket))
+	latest.conf = c
+	l.latest.Store(latest)
+	return latest
+}
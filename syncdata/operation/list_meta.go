@@ -0,0 +1,180 @@
+package operation
+
+import (
+	"context"
+
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/kodo"
+	"github.com/qiniupd/qiniu-go-sdk/x/httputil.v1"
+	"github.com/qiniupd/qiniu-go-sdk/x/kvlog.v1"
+)
+
+// 获取指定对象的详细元信息，包括自定义元信息、存储类型、状态、md5 和解冻状态
+func (l *Lister) StatDetail(key string) (entry kodo.EntryDetail, err error) {
+	err = l.current().rsCall("stat", func(bucket kodo.Bucket) (err error) {
+		entry, err = bucket.StatDetail(nil, key)
+		return
+	})
+	return
+}
+
+// 批量获取对象的详细元信息，返回结果与 keys 一一对应
+func (l *Lister) BatchStatDetail(keys []string) ([]kodo.BatchStatDetailItemRet, error) {
+	l = l.current()
+	rets := make([]kodo.BatchStatDetailItemRet, 0, len(keys))
+	err := l.eachBatch(keys, func(bucket kodo.Bucket, keys []string) error {
+		r, err := bucket.BatchStatDetail(nil, keys...)
+		if err == nil {
+			rets = append(rets, r...)
+		}
+		return err
+	})
+	return rets, err
+}
+
+// 设置对象的自定义元信息，meta 的 key 不需要 x-qn-meta- 前缀，会覆盖对象已有的自定义元信息
+func (l *Lister) SetMeta(key string, meta map[string]string) error {
+	return l.current().rsCall("chgm", func(bucket kodo.Bucket) error {
+		return bucket.SetMeta(nil, key, meta)
+	})
+}
+
+// 修改对象的存储类型
+func (l *Lister) ChangeType(key string, fileType kodo.FileType) error {
+	return l.current().rsCall("chtype", func(bucket kodo.Bucket) error {
+		return bucket.ChangeType(nil, key, fileType)
+	})
+}
+
+// 设置对象在指定天数后自动删除，days 为 0 时取消自动删除
+func (l *Lister) DeleteAfterDays(key string, days int) error {
+	return l.current().rsCall("deleteAfterDays", func(bucket kodo.Bucket) error {
+		return bucket.DeleteAfterDays(nil, key, days)
+	})
+}
+
+// 修改对象状态，status 为 kodo.StatusEnabled 或 kodo.StatusDisabled
+func (l *Lister) ChangeStatus(key string, status int) error {
+	return l.current().rsCall("chstatus", func(bucket kodo.Bucket) error {
+		return bucket.ChangeStatus(nil, key, status)
+	})
+}
+
+// 批量设置对象的自定义元信息，返回结果与 keys 一一对应
+func (l *Lister) BatchSetMeta(keys []string, meta map[string]string) ([]kodo.BatchItemRet, error) {
+	return l.current().batchKeys(keys, func(bucket kodo.Bucket, keys []string) ([]kodo.BatchItemRet, error) {
+		return bucket.BatchSetMeta(nil, meta, keys...)
+	})
+}
+
+// 批量修改对象的存储类型，返回结果与 keys 一一对应
+func (l *Lister) BatchChangeType(keys []string, fileType kodo.FileType) ([]kodo.BatchItemRet, error) {
+	return l.current().batchKeys(keys, func(bucket kodo.Bucket, keys []string) ([]kodo.BatchItemRet, error) {
+		return bucket.BatchChangeType(nil, fileType, keys...)
+	})
+}
+
+// 批量设置对象的自动删除天数，返回结果与 keys 一一对应
+func (l *Lister) BatchDeleteAfterDays(keys []string, days int) ([]kodo.BatchItemRet, error) {
+	return l.current().batchKeys(keys, func(bucket kodo.Bucket, keys []string) ([]kodo.BatchItemRet, error) {
+		return bucket.BatchDeleteAfterDays(nil, days, keys...)
+	})
+}
+
+// 批量修改对象状态，返回结果与 keys 一一对应
+func (l *Lister) BatchChangeStatus(keys []string, status int) ([]kodo.BatchItemRet, error) {
+	return l.current().batchKeys(keys, func(bucket kodo.Bucket, keys []string) ([]kodo.BatchItemRet, error) {
+		return bucket.BatchChangeStatus(nil, status, keys...)
+	})
+}
+
+// 解冻归档存储对象，解冻完成后 freezeAfterDays 天内可以下载
+func (l *Lister) RestoreAr(key string, freezeAfterDays int) error {
+	return l.current().rsCall("restoreAr", func(bucket kodo.Bucket) error {
+		return bucket.RestoreAr(nil, key, freezeAfterDays)
+	})
+}
+
+// 批量解冻归档存储对象，返回结果与 keys 一一对应
+func (l *Lister) BatchRestoreAr(keys []string, freezeAfterDays int) ([]kodo.BatchItemRet, error) {
+	return l.current().batchKeys(keys, func(bucket kodo.Bucket, keys []string) ([]kodo.BatchItemRet, error) {
+		return bucket.BatchRestoreAr(nil, freezeAfterDays, keys...)
+	})
+}
+
+// rsCall 在 rs 服务上执行 fn，失败时换一个 rs 域名重试一次
+func (l *Lister) rsCall(name string, fn func(bucket kodo.Bucket) error) error {
+	return hostCall("rs", name, l.nextRsHost, func(host string) error {
+		return fn(l.newBucket(host, ""))
+	})
+}
+
+// rsfCall 在 rsf 服务上执行 fn，失败时换一个 rsf 域名重试一次
+func (l *Lister) rsfCall(name string, fn func(bucket kodo.Bucket) error) error {
+	rsHost, err := l.nextRsHost()
+	if err != nil {
+		return err
+	}
+	return hostCall("rsf", name, l.nextRsfHost, func(host string) error {
+		return fn(l.newBucket(rsHost, host))
+	})
+}
+
+// hostCall 在 nextHost 选出的域名上执行 fn，失败时换一个域名重试一次；
+// 612（对象不存在）是正常的业务结果，不会重试，也不会标记域名失败
+func hostCall(service, name string, nextHost func() (string, error), fn func(host string) error) error {
+	host, err := nextHost()
+	if err != nil {
+		return err
+	}
+	err = fn(host)
+	if err != nil {
+		if code := httputil.DetectCode(err); code == 612 {
+			succeedHostName(host)
+			return err
+		}
+		failHostName(host)
+		logger.Info(context.Background(), name+" retry 0", kvlog.F("host", host), kvlog.F("error", err))
+		recordRetry(service, name)
+		if host, err = nextHost(); err != nil {
+			return err
+		}
+		err = fn(host)
+		if err != nil {
+			failHostName(host)
+			logger.Info(context.Background(), name+" retry 1", kvlog.F("host", host), kvlog.F("error", err))
+			return err
+		}
+	}
+	succeedHostName(host)
+	return nil
+}
+
+// batchKeys 按 batchSize 分批执行批量操作
+func (l *Lister) batchKeys(keys []string, fn func(bucket kodo.Bucket, keys []string) ([]kodo.BatchItemRet, error)) ([]kodo.BatchItemRet, error) {
+	rets := make([]kodo.BatchItemRet, 0, len(keys))
+	err := l.eachBatch(keys, func(bucket kodo.Bucket, keys []string) error {
+		r, err := fn(bucket, keys)
+		if err == nil {
+			rets = append(rets, r...)
+		}
+		return err
+	})
+	return rets, err
+}
+
+// eachBatch 将 keys 按 batchSize 分批，每批在 rs 服务上执行 fn，失败时重试一次
+func (l *Lister) eachBatch(keys []string, fn func(bucket kodo.Bucket, keys []string) error) error {
+	for i := 0; i < len(keys); i += l.batchSize {
+		size := l.batchSize
+		if size > len(keys)-i {
+			size = len(keys) - i
+		}
+		batch := keys[i : i+size]
+		if err := l.rsCall("batch", func(bucket kodo.Bucket) error {
+			return fn(bucket, batch)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,19 +2,26 @@ package operation
 
 import (
 	"github.com/qiniupd/qiniu-go-sdk/api.v8/kodocli"
+	"github.com/qiniupd/qiniu-go-sdk/x/kvlog.v1"
 )
 
-// elog is embedded logger
-var elog kodocli.Ilog
+// logger 是结构化日志记录器
+var logger *kvlog.Logger
 
 // 设置全局 Logger
-func SetLogger(logger kodocli.Ilog) {
-	elog = logger
-	kodocli.SetLogger(logger)
+func SetLogger(l kodocli.Ilog) {
+	logger = kvlog.New(kvlog.FromPrinter(l))
+	kodocli.SetLogger(l)
+}
+
+// 设置全局结构化 Logger，kodocli 中的日志以消息形式输出到该 Logger
+func SetKVLogger(l *kvlog.Logger) {
+	logger = l
+	kodocli.SetKVLogger(l)
 }
 
 func init() {
-	if elog == nil {
-		elog = kodocli.NewLogger()
+	if logger == nil {
+		logger = kvlog.New(kvlog.FromPrinter(kodocli.NewLogger()))
 	}
 }
@@ -0,0 +1,31 @@
+package operation
+
+import (
+	"github.com/qiniupd/qiniu-go-sdk/x/metrics.v1"
+)
+
+func recordRetry(service, api string) {
+	if metrics.Enabled() {
+		metrics.AddCounter(metrics.Retries, 1, metrics.L("service", service), metrics.L("api", api))
+	}
+}
+
+// recordHostFailure 记录域名失败次数，以及域名因此从可用变为被屏蔽的次数
+func recordHostFailure(hostName string, wasValid, isValid bool) {
+	metrics.AddCounter(metrics.HostFailures, 1, metrics.L("host", hostName))
+	if wasValid && !isValid {
+		metrics.AddCounter(metrics.HostBlacklisted, 1, metrics.L("host", hostName))
+	}
+}
+
+func recordQueueDepth(depth int) {
+	if metrics.Enabled() {
+		metrics.SetGauge(metrics.JobQueueDepth, float64(depth))
+	}
+}
+
+func recordJobFinished(state JobState) {
+	if metrics.Enabled() {
+		metrics.AddCounter(metrics.JobsTotal, 1, metrics.L("state", string(state)))
+	}
+}
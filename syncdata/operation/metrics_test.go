@@ -0,0 +1,40 @@
+package operation
+
+import (
+	"testing"
+
+	"github.com/qiniupd/qiniu-go-sdk/x/metrics.v1"
+)
+
+// plainRecorder 是不能作为 http.Handler 的记录器
+type plainRecorder struct{}
+
+func (plainRecorder) AddCounter(string, float64, ...metrics.Label) {}
+func (plainRecorder) SetGauge(string, float64, ...metrics.Label)   {}
+func (plainRecorder) Observe(string, float64, ...metrics.Label)    {}
+
+func TestNewMetricsHandler(t *testing.T) {
+	defer metrics.SetRecorder(metrics.GetRecorder())
+
+	metrics.SetRecorder(nil)
+	if h := newMetricsHandler(&Config{}); h != nil || metrics.GetRecorder() != nil {
+		t.Fatal("metrics disabled should not install a recorder")
+	}
+
+	h := newMetricsHandler(&Config{Metrics: true})
+	if registry, ok := metrics.GetRecorder().(*metrics.Registry); !ok || h != registry {
+		t.Fatal("a Registry should be installed when no recorder is set")
+	}
+
+	registry := metrics.NewRegistry()
+	metrics.SetRecorder(registry)
+	if h := newMetricsHandler(&Config{Metrics: true}); h != registry {
+		t.Fatal("an existing Registry should be served as is")
+	}
+
+	user := plainRecorder{}
+	metrics.SetRecorder(user)
+	if h := newMetricsHandler(&Config{Metrics: true}); h != nil || metrics.GetRecorder() != user {
+		t.Fatal("a user recorder should be kept and /metrics disabled")
+	}
+}
@@ -15,21 +15,26 @@ import (
 	"time"
 
 	"github.com/kirsle/configdir"
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/auth/qbox"
+	"github.com/qiniupd/qiniu-go-sdk/x/metrics.v1"
 	"github.com/qiniupd/qiniu-go-sdk/x/rpc.v7"
 )
 
 var queryClient = &http.Client{
-	Transport: &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   500 * time.Millisecond,
-			KeepAlive: 30 * time.Second,
-			DualStack: true,
-		}).DialContext,
-		MaxIdleConns:          100,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
+	Transport: &metrics.Transport{
+		Service: "uc",
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   500 * time.Millisecond,
+				KeepAlive: 30 * time.Second,
+				DualStack: true,
+			}).DialContext,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
 	},
 	Timeout: 1 * time.Second,
 }
@@ -44,9 +49,9 @@ var (
 type (
 	// 域名查询器
 	Queryer struct {
-		ak      string
-		bucket  string
-		ucHosts []string
+		credentials qbox.CredentialsProvider
+		bucket      string
+		ucHosts     []string
 	}
 
 	cache struct {
@@ -78,14 +83,26 @@ func init() {
 // 根据配置创建域名查询器
 func NewQueryer(c *Config) *Queryer {
 	queryer := Queryer{
-		ak:      c.Ak,
-		bucket:  c.Bucket,
-		ucHosts: dupStrings(c.UcHosts),
+		credentials: newCredentials(c),
+		bucket:      c.Bucket,
+		ucHosts:     dupStrings(c.UcHosts),
 	}
 	shuffleHosts(queryer.ucHosts)
 	return &queryer
 }
 
+// 返回查询指定存储空间的域名查询器，查询结果按存储空间分别缓存
+func (queryer *Queryer) WithBucket(bucket string) *Queryer {
+	if queryer == nil {
+		return nil
+	}
+	return &Queryer{
+		credentials: queryer.credentials,
+		bucket:      bucket,
+		ucHosts:     queryer.ucHosts,
+	}
+}
+
 // 查询 UP 服务器 URL
 func (queryer *Queryer) QueryUpHosts(https bool) (urls []string) {
 	if cache, err := queryer.query(); err == nil {
@@ -169,12 +186,22 @@ func (queryer *Queryer) mustQuery() (c *cache, err error) {
 	var req *http.Request
 	var resp *http.Response
 
+	mac, err := queryer.credentials.Retrieve()
+	if err != nil {
+		return nil, err
+	}
 	query := make(url.Values, 2)
-	query.Set("ak", queryer.ak)
+	query.Set("ak", mac.AccessKey)
 	query.Set("bucket", queryer.bucket)
 
 	for i := 0; i < 10; i++ {
-		ucHost := queryer.nextUcHost()
+		if i > 0 {
+			recordRetry("uc", "v4")
+		}
+		ucHost, hostErr := queryer.nextUcHost()
+		if hostErr != nil {
+			return nil, hostErr
+		}
 		url := fmt.Sprintf("%s/v4/query?%s", ucHost, query.Encode())
 		req, err = http.NewRequest(http.MethodGet, url, http.NoBody)
 		if err != nil {
@@ -249,17 +276,24 @@ func (queryer *Queryer) setCache(c *cache) {
 }
 
 func (queryer *Queryer) cacheKey() string {
-	return fmt.Sprintf("%s:%s", queryer.bucket, queryer.ak)
+	return fmt.Sprintf("%s:%s", queryer.bucket, queryer.accessKey())
+}
+
+func (queryer *Queryer) accessKey() string {
+	if mac, err := queryer.credentials.Retrieve(); err == nil {
+		return mac.AccessKey
+	}
+	return ""
 }
 
 var curUcHostIndex uint32 = 0
 
-func (queryer *Queryer) nextUcHost() string {
+func (queryer *Queryer) nextUcHost() (string, error) {
 	switch len(queryer.ucHosts) {
 	case 0:
-		panic("No Uc hosts is configured")
+		return "", errNoHosts("uc")
 	case 1:
-		return queryer.ucHosts[0]
+		return queryer.ucHosts[0], nil
 	default:
 		var ucHost string
 		for i := 0; i <= len(queryer.ucHosts)*MaxFindHostsPrecent/100; i++ {
@@ -269,7 +303,7 @@ func (queryer *Queryer) nextUcHost() string {
 				break
 			}
 		}
-		return ucHost
+		return ucHost, nil
 	}
 }
 
@@ -0,0 +1,105 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/kodo"
+	"github.com/qiniupd/qiniu-go-sdk/x/kvlog.v1"
+)
+
+// 等待归档存储对象解冻超时
+var ErrRestoreTimeout = errors.New("archive restore timeout")
+
+const (
+	defaultRestoreDays    = 1
+	defaultRestoreTimeout = 6 * time.Hour
+	minRestorePoll        = 10 * time.Second
+	maxRestorePoll        = 5 * time.Minute
+)
+
+// 未解冻的归档存储对象下载时返回的状态码，只有该状态码才会触发自动解冻
+const archiveFrozenCode = http.StatusForbidden
+
+// 归档存储对象的解冻器，由 Downloader 在 AutoRestore 开启时使用
+type archiveRestorer struct {
+	lister  *Lister
+	days    int
+	timeout time.Duration
+	minPoll time.Duration
+	maxPoll time.Duration
+	after   func(d time.Duration) <-chan time.Time // 等待下一次查询，默认为 time.After
+}
+
+func newArchiveRestorer(c *Config) *archiveRestorer {
+	if !c.AutoRestore {
+		return nil
+	}
+	r := &archiveRestorer{
+		lister:  NewLister(c),
+		days:    c.RestoreDays,
+		timeout: time.Duration(c.RestoreTimeout) * time.Second,
+		minPoll: minRestorePoll,
+		maxPoll: maxRestorePoll,
+		after:   time.After,
+	}
+	if r.days <= 0 {
+		r.days = defaultRestoreDays
+	}
+	if r.timeout <= 0 {
+		r.timeout = defaultRestoreTimeout
+	}
+	return r
+}
+
+func (r *archiveRestorer) withBucket(bucket string) *archiveRestorer {
+	if r == nil {
+		return nil
+	}
+	r1 := *r
+	r1.lister = r.lister.WithBucket(bucket)
+	return &r1
+}
+
+// waitRestored 在对象为未解冻的归档存储对象时发起解冻，并以指数退避的间隔等待解冻完成。
+// 对象不是归档存储或者已经解冻时返回 false；超过 timeout 时返回 ErrRestoreTimeout，ctx 取消时返回 ctx.Err()
+func (r *archiveRestorer) waitRestored(ctx context.Context, key string) (bool, error) {
+	entry, err := r.lister.StatDetail(key)
+	if err != nil {
+		return false, err
+	}
+	if entry.Type != kodo.TypeArchive || entry.RestoreStatus == kodo.RestoreStatusRestored {
+		return false, nil
+	}
+	if entry.RestoreStatus == kodo.RestoreStatusFrozen {
+		logger.Info(ctx, "restore archive object", kvlog.F("key", key), kvlog.F("freeze_after_days", r.days))
+		if err = r.lister.RestoreAr(key, r.days); err != nil {
+			return false, err
+		}
+	}
+
+	deadline := time.Now().Add(r.timeout)
+	interval := r.minPoll
+	for {
+		if time.Now().Add(interval).After(deadline) {
+			return false, ErrRestoreTimeout
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-r.after(interval):
+		}
+		if entry, err = r.lister.StatDetail(key); err != nil {
+			return false, err
+		}
+		if entry.RestoreStatus == kodo.RestoreStatusRestored {
+			logger.Info(ctx, "archive object restored", kvlog.F("key", key))
+			return true, nil
+		}
+		if interval *= 2; interval > r.maxPoll {
+			interval = r.maxPoll
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package operation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/kodo"
+	"github.com/qiniupd/qiniu-go-sdk/x/httputil.v1"
+)
+
+// archiveServer 模拟 rs 和 io 服务，对象在 restoreAfter 次 stat 之后解冻完成
+type archiveServer struct {
+	mu           sync.Mutex
+	fileType     kodo.FileType
+	status       int
+	restoreAfter int
+	stats        int
+	restores     int
+	downloadCode int // 不为 0 时下载总是返回该状态码
+}
+
+func (s *archiveServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case strings.HasPrefix(req.URL.Path, "/stat/"):
+		s.stats++
+		if s.status == kodo.RestoreStatusRestoring && s.stats > s.restoreAfter {
+			s.status = kodo.RestoreStatusRestored
+		}
+		json.NewEncoder(w).Encode(kodo.EntryDetail{Type: s.fileType, RestoreStatus: s.status})
+	case strings.HasPrefix(req.URL.Path, "/restoreAr/"):
+		s.restores++
+		s.status = kodo.RestoreStatusRestoring
+		w.Write([]byte(`{}`))
+	case strings.HasPrefix(req.URL.Path, "/getfile/"):
+		switch {
+		case s.downloadCode != 0:
+			w.WriteHeader(s.downloadCode)
+		case s.fileType == kodo.TypeArchive && s.status != kodo.RestoreStatusRestored:
+			w.WriteHeader(archiveFrozenCode)
+		default:
+			w.Write([]byte("data"))
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newRestoreDownloader(t *testing.T, s *archiveServer) (*Downloader, *[]time.Duration) {
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	d := NewDownloader(&Config{
+		Ak: "ak", Sk: "sk", Bucket: "b",
+		RsHosts: []string{srv.URL}, IoHosts: []string{srv.URL},
+		AutoRestore: true, RestoreTimeout: 3600,
+	})
+	var intervals []time.Duration
+	d.restorer.minPoll = time.Second
+	d.restorer.maxPoll = 4 * time.Second
+	d.restorer.after = func(d time.Duration) <-chan time.Time {
+		intervals = append(intervals, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+	return d, &intervals
+}
+
+func TestDownloadRestoresArchive(t *testing.T) {
+	s := &archiveServer{fileType: kodo.TypeArchive, restoreAfter: 6}
+	d, intervals := newRestoreDownloader(t, s)
+
+	data, err := d.DownloadBytes("a.car")
+	if err != nil || string(data) != "data" {
+		t.Fatal("DownloadBytes:", string(data), err)
+	}
+	if s.restores != 1 {
+		t.Fatal("restoreAr should be called once, got", s.restores)
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second, 4 * time.Second, 4 * time.Second}
+	if len(*intervals) != len(want) {
+		t.Fatal("unexpected poll intervals:", *intervals)
+	}
+	for i := range want {
+		if (*intervals)[i] != want[i] {
+			t.Fatal("unexpected poll intervals:", *intervals)
+		}
+	}
+}
+
+func TestDownloadKeepsOriginalError(t *testing.T) {
+	cases := []struct {
+		name   string
+		server *archiveServer
+		stats  int
+	}{
+		// 对象不是归档存储，返回下载的原始错误
+		{name: "not archived", server: &archiveServer{fileType: kodo.TypeNormal, downloadCode: archiveFrozenCode}, stats: 1},
+		// 不是 archiveFrozenCode 的错误不会触发解冻
+		{name: "other error", server: &archiveServer{fileType: kodo.TypeArchive, downloadCode: http.StatusNotFound}, stats: 0},
+	}
+	for _, c := range cases {
+		d, _ := newRestoreDownloader(t, c.server)
+		_, err := d.DownloadBytes("a.car")
+		if code := httputil.DetectCode(err); code != c.server.downloadCode {
+			t.Errorf("%s: error %v (code %d), want code %d", c.name, err, code, c.server.downloadCode)
+		}
+		if c.server.stats != c.stats || c.server.restores != 0 {
+			t.Errorf("%s: stats %d restores %d", c.name, c.server.stats, c.server.restores)
+		}
+	}
+}
+
+func TestWaitRestoredTimeout(t *testing.T) {
+	s := &archiveServer{fileType: kodo.TypeArchive, restoreAfter: 100}
+	d, intervals := newRestoreDownloader(t, s)
+	d.restorer.timeout = 3 * time.Second
+
+	if _, err := d.DownloadBytes("a.car"); err != ErrRestoreTimeout {
+		t.Fatal("expected ErrRestoreTimeout, got", err)
+	}
+	if len(*intervals) != 2 {
+		t.Fatal("unexpected poll intervals:", *intervals)
+	}
+}
+
+func TestWaitRestoredCanceled(t *testing.T) {
+	s := &archiveServer{fileType: kodo.TypeArchive, restoreAfter: 100}
+	d, _ := newRestoreDownloader(t, s)
+	d.restorer.after = func(time.Duration) <-chan time.Time { return nil }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := d.DownloadRangeReaderWithContext(ctx, "a.car", 0); err != context.Canceled {
+		t.Fatal("expected context.Canceled, got", err)
+	}
+}
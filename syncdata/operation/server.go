@@ -1,20 +1,45 @@
 package operation
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
-	"log"
+	"errors"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/kodo"
+	"github.com/qiniupd/qiniu-go-sdk/x/kvlog.v1"
+	"github.com/qiniupd/qiniu-go-sdk/x/metrics.v1"
+	"github.com/qiniupd/qiniu-go-sdk/x/xlog.v8"
 )
 
 type server struct {
-	up       *Uploader
-	lister   *Lister
-	del      bool
-	downPath string
-	sim      bool
+	auth      *serverAuth
+	roots     *pathAllowList
+	disabled  serverEndpoints
+	metrics   http.Handler
+	jobs      *jobManager
+	lister    *Lister
+	remote    *BucketFileSystem
+	downPath  string
+	downCache bool
+	sim       bool
+	caching   sync.Map
+	ctx       context.Context // Shutdown 时取消，用于结束后台缓存任务
+}
+
+// serverEndpoints 记录被关闭的接口
+type serverEndpoints struct {
+	upload   bool
+	download bool
+	list     bool
+	stat     bool
 }
 
 type Req struct {
@@ -33,37 +58,123 @@ func (s *server) download(res http.ResponseWriter, req *http.Request) {
 	path := req.URL.Path
 	fPath := s.downPath + renameFile(path)
 	f, err := os.Open(fPath)
-	elog.Info("download", req.Method, path, req.URL.RawQuery, err)
-	elog.Info("ranges", req.Header.Get("Range"))
+	logger.Info(req.Context(), "download", kvlog.F("method", req.Method), kvlog.F("path", path),
+		kvlog.F("query", req.URL.RawQuery), kvlog.F("range", req.Header.Get("Range")), kvlog.F("error", err))
 	if err != nil {
-		res.WriteHeader(http.StatusNotFound)
+		if s.sim || !os.IsNotExist(err) {
+			res.WriteHeader(http.StatusNotFound)
+			return
+		}
+		s.downloadRemote(res, req, path)
 		return
 	}
 	ServeContent(res, req, path, time.Now(), f)
 	f.Close()
 }
 
+// 本地不存在时从存储空间中读取对象，Range 请求直接转换为对存储空间的范围读取
+func (s *server) downloadRemote(res http.ResponseWriter, req *http.Request, path string) {
+	key := strings.TrimPrefix(path, "/")
+	f, err := s.remote.open(req.Context(), key)
+	if err != nil {
+		logger.Info(req.Context(), "open remote failed", kvlog.F("key", key), kvlog.F("error", err))
+		msg, code := toHTTPError(err)
+		http.Error(res, msg, code)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		res.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if entry, ok := info.Sys().(kodo.Entry); ok {
+		res.Header().Set("Etag", strconv.Quote(entry.Hash))
+		if entry.MimeType != "" {
+			res.Header().Set("Content-Type", entry.MimeType)
+		}
+	}
+	if s.downCache {
+		s.cacheRemote(key, s.downPath+renameFile(path), info.Size())
+	}
+
+	sizeFunc := func() (int64, error) { return info.Size(), nil }
+	serveContent(res, req, path, info.ModTime(), sizeFunc, f)
+}
+
+// 在后台将对象下载到本地缓存，下载完成后再重命名为正式文件，同一对象同时只会下载一次
+func (s *server) cacheRemote(key, fPath string, size int64) {
+	if _, loaded := s.caching.LoadOrStore(fPath, struct{}{}); loaded {
+		return
+	}
+	go func() {
+		defer s.caching.Delete(fPath)
+		tmpPath := fPath + ".downloading"
+		f, err := s.remote.downloader.DownloadFileWithContext(s.ctx, key, tmpPath)
+		if err != nil {
+			logger.Warn(context.Background(), "cache remote failed", kvlog.F("key", key), kvlog.F("error", err))
+			return
+		}
+		info, err := f.Stat()
+		f.Close()
+		if err != nil || info.Size() != size {
+			logger.Warn(context.Background(), "cache remote size mismatch", kvlog.F("key", key), kvlog.F("size", size), kvlog.F("error", err))
+			os.Remove(tmpPath)
+			return
+		}
+		if err = os.Rename(tmpPath, fPath); err != nil {
+			logger.Warn(context.Background(), "cache remote rename failed", kvlog.F("key", key), kvlog.F("error", err))
+		}
+	}()
+}
+
 func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	xl := xlog.New(w, r)
+	r = r.WithContext(xlog.NewContext(r.Context(), xl))
+	if !s.auth.verify(r) {
+		logger.Warn(r.Context(), "unauthorized request", kvlog.F("method", r.Method), kvlog.F("path", r.URL.Path), kvlog.F("remote", r.RemoteAddr))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
 	switch r.Method {
 	case http.MethodPost:
 		if r.URL.Path == "/stat" {
-			s.listStat(w, r)
+			s.serve(s.disabled.stat, s.listStat, w, r)
 		} else {
-			s.upload(w, r)
+			s.serve(s.disabled.upload, s.upload, w, r)
 		}
 	case http.MethodHead:
 		fallthrough
 	case http.MethodGet:
 		if r.URL.Path == "/list" {
-			s.listFiles(w, r)
+			s.serve(s.disabled.list, s.listFiles, w, r)
+		} else if r.URL.Path == "/metrics" && s.metrics != nil {
+			s.metrics.ServeHTTP(w, r)
+		} else if strings.HasPrefix(r.URL.Path, jobsPrefix) {
+			s.serve(s.disabled.upload, s.getJob, w, r)
+		} else {
+			s.serve(s.disabled.download, s.download, w, r)
+		}
+	case http.MethodDelete:
+		if strings.HasPrefix(r.URL.Path, jobsPrefix) {
+			s.serve(s.disabled.upload, s.cancelJob, w, r)
 		} else {
-			s.download(w, r)
+			w.WriteHeader(http.StatusMethodNotAllowed)
 		}
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
 
+func (s *server) serve(disabled bool, handler http.HandlerFunc, w http.ResponseWriter, r *http.Request) {
+	if disabled {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	handler(w, r)
+}
+
 func (s *server) listStat(w http.ResponseWriter, r *http.Request) {
 	ret := s.lister.batchStat(r.Body)
 	if ret == nil {
@@ -72,7 +183,7 @@ func (s *server) listStat(w http.ResponseWriter, r *http.Request) {
 	}
 	j, err := json.Marshal(ret)
 	if err != nil {
-		log.Println("json marshal error", err)
+		logger.Error(r.Context(), "json marshal failed", kvlog.F("error", err))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -89,7 +200,7 @@ func (s *server) listFiles(w http.ResponseWriter, r *http.Request) {
 	}
 	j, err := json.Marshal(ret)
 	if err != nil {
-		log.Println("json marshal error", err)
+		logger.Error(r.Context(), "json marshal failed", kvlog.F("error", err))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -101,54 +212,175 @@ func (s *server) upload(w http.ResponseWriter, r *http.Request) {
 	d := json.NewDecoder(r.Body)
 	var reqs []Req
 	err := d.Decode(&reqs)
-	log.Printf("receive request %+v\n", reqs)
 	if err != nil {
+		logger.Warn(r.Context(), "decode upload request failed", kvlog.F("error", err))
 		w.WriteHeader(http.StatusBadRequest)
-		log.Println(err)
 		return
 	}
-	go func() {
-		for _, req := range reqs {
-			if s.sim {
-				err := os.Rename(req.Path, s.downPath+renameFile(req.Path))
-				log.Println("move ", req.Path, s.downPath+renameFile(req.Path), err)
-			} else {
-				key := req.Key
-				if key == "" {
-					key = req.Path
-				}
-				s.up.Upload(req.Path, key)
-				if req.Delete == nil {
-					if s.del {
-						os.Remove(req.Path)
-					}
-				} else if *req.Delete {
-					os.Remove(req.Path)
-				}
-			}
+	logger.Info(r.Context(), "receive upload request", kvlog.F("files", len(reqs)))
+	for _, req := range reqs {
+		if !s.roots.allowed(req.Path) {
+			logger.Warn(r.Context(), "upload path not allowed", kvlog.F("path", req.Path))
+			w.WriteHeader(http.StatusForbidden)
+			return
 		}
-	}()
+	}
+	status, err := s.jobs.submit(reqs)
+	if err != nil {
+		logger.Warn(r.Context(), "submit job failed", kvlog.F("error", err))
+		if err == ErrJobQueueFull {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		} else if errors.Is(err, ErrInvalidKey) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		ID string `json:"id"`
+	}{status.ID})
+}
 
-	w.WriteHeader(http.StatusOK)
+const jobsPrefix = "/jobs/"
+
+func (s *server) getJob(w http.ResponseWriter, r *http.Request) {
+	status, err := s.jobs.get(strings.TrimPrefix(r.URL.Path, jobsPrefix))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
 }
 
+func (s *server) cancelJob(w http.ResponseWriter, r *http.Request) {
+	status, err := s.jobs.cancel(strings.TrimPrefix(r.URL.Path, jobsPrefix))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	j, err := json.Marshal(v)
+	if err != nil {
+		logger.Error(context.Background(), "json marshal failed", kvlog.F("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(j)
+}
+
+// newMetricsHandler 返回 /metrics 接口的处理器。未设置全局指标记录器时创建并设置 Registry；
+// 已设置的记录器不是 http.Handler 时保留该记录器，不开启 /metrics 接口
+func newMetricsHandler(cfg *Config) http.Handler {
+	if !cfg.Metrics {
+		return nil
+	}
+	recorder := metrics.GetRecorder()
+	if recorder == nil {
+		registry := metrics.NewRegistry()
+		metrics.SetRecorder(registry)
+		return registry
+	}
+	if h, ok := recorder.(http.Handler); ok {
+		return h
+	}
+	logger.Warn(context.Background(), "metrics recorder is not an http.Handler, /metrics is disabled")
+	return nil
+}
+
+// 启动上传服务，配置不合法时返回 *ConfigError；配置了 TLSCertFile 和 TLSKeyFile 时使用 HTTPS。
+// 监听端口和加载证书失败时返回错误，服务启动后的错误只记录日志，服务通过返回的 *http.Server 关闭
 func StartServer(cfg *Config) (*http.Server, error) {
-	s := server{
-		up:       NewUploader(cfg),
-		del:      cfg.Delete,
-		downPath: cfg.DownPath,
-		sim:      cfg.Sim,
-		lister:   NewLister(cfg),
+	validate := cfg.validateUpload
+	if cfg.DisableUpload {
+		validate = cfg.Validate
+	}
+	if err := validate(); err != nil {
+		return nil, err
+	}
+	roots, err := newPathAllowList(cfg.AllowedRoots)
+	if err != nil {
+		return nil, err
+	}
+	auth := newServerAuth(cfg)
+	if !auth.enabled() {
+		logger.Warn(context.Background(), "upload server is running without authentication")
+	}
+	metricsHandler := newMetricsHandler(cfg)
+	jobs, err := newJobManager(cfg, NewUploader(cfg))
+	if err != nil {
+		return nil, err
+	}
+	lister := NewLister(cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &server{
+		ctx:   ctx,
+		auth:  auth,
+		roots: roots,
+		disabled: serverEndpoints{
+			upload:   cfg.DisableUpload,
+			download: cfg.DisableDownload,
+			list:     cfg.DisableList,
+			stat:     cfg.DisableStat,
+		},
+		metrics:   metricsHandler,
+		jobs:      jobs,
+		downPath:  cfg.DownPath,
+		downCache: cfg.DownCache,
+		sim:       cfg.Sim,
+		lister:    lister,
+		remote: &BucketFileSystem{
+			lister:     lister,
+			downloader: NewDownloader(cfg),
+		},
 	}
 	srv := &http.Server{
 		Addr:    cfg.Addr,
-		Handler: &s,
+		Handler: s,
+	}
+	shutdown := func() {
+		cancel()
+		if err := jobs.close(); err != nil {
+			logger.Error(context.Background(), "close job manager failed", kvlog.F("error", err))
+		}
+	}
+	srv.RegisterOnShutdown(shutdown)
+	if cfg.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			shutdown()
+			return nil, err
+		}
+		srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	addr := cfg.Addr
+	if addr == "" {
+		addr = ":http"
+		if srv.TLSConfig != nil {
+			addr = ":https"
+		}
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		shutdown()
+		return nil, err
 	}
 
 	go func() {
 		// service connections
-		if err := srv.ListenAndServe(); err != nil {
-			log.Panicln("upload server failed: " + err.Error())
+		var err error
+		if srv.TLSConfig != nil {
+			err = srv.ServeTLS(ln, "", "")
+		} else {
+			err = srv.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logger.Error(context.Background(), "upload server failed", kvlog.F("error", err))
 		}
 	}()
 	return srv, nil
@@ -0,0 +1,119 @@
+package operation
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newDownloadTestServer(t *testing.T, downCache bool) (*server, *fakeBucket) {
+	fs, b := newTestFileSystem(t)
+	return &server{
+		ctx:       context.Background(),
+		remote:    fs,
+		downPath:  t.TempDir() + string(filepath.Separator),
+		downCache: downCache,
+	}, b
+}
+
+func TestDownloadRemote(t *testing.T) {
+	s, b := newDownloadTestServer(t, false)
+	lastModified := putTimeToTime(fakePutTime).UTC().Format(http.TimeFormat)
+
+	cases := []struct {
+		name     string
+		path     string
+		header   map[string]string
+		code     int
+		body     string
+		download string // 期望对存储空间发出的 Range 请求，"-" 表示没有下载请求
+		check    map[string]string
+	}{
+		{name: "whole object", path: "/dir/a.txt", code: http.StatusOK, body: "0123456789",
+			check: map[string]string{"Etag": `"h-dir/a.txt"`, "Last-Modified": lastModified, "Content-Length": "10"}},
+		{name: "range", path: "/dir/a.txt", header: map[string]string{"Range": "bytes=2-4"}, code: http.StatusPartialContent, body: "234", download: "bytes=2-",
+			check: map[string]string{"Content-Range": "bytes 2-4/10", "Etag": `"h-dir/a.txt"`, "Content-Length": "3"}},
+		{name: "suffix range", path: "/dir/a.txt", header: map[string]string{"Range": "bytes=-3"}, code: http.StatusPartialContent, body: "789", download: "bytes=7-",
+			check: map[string]string{"Content-Range": "bytes 7-9/10"}},
+		{name: "etag matched", path: "/dir/a.txt", header: map[string]string{"If-None-Match": `"h-dir/a.txt"`}, code: http.StatusNotModified, download: "-"},
+		{name: "not modified since", path: "/dir/a.txt", header: map[string]string{"If-Modified-Since": lastModified}, code: http.StatusNotModified, download: "-"},
+		{name: "missing object", path: "/missing", code: http.StatusNotFound, download: "-"},
+		{name: "directory", path: "/dir", code: http.StatusNotFound, download: "-"},
+	}
+	for _, c := range cases {
+		b.ranges = nil
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		for k, v := range c.header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		s.download(rec, req)
+
+		if rec.Code != c.code {
+			t.Errorf("%s: code %d, want %d", c.name, rec.Code, c.code)
+			continue
+		}
+		if c.body != "" && rec.Body.String() != c.body {
+			t.Errorf("%s: body %q, want %q", c.name, rec.Body.String(), c.body)
+		}
+		for k, v := range c.check {
+			if got := rec.Header().Get(k); got != v {
+				t.Errorf("%s: header %s %q, want %q", c.name, k, got, v)
+			}
+		}
+		switch {
+		case c.download == "-" && len(b.ranges) != 0:
+			t.Errorf("%s: unexpected download requests %q", c.name, b.ranges)
+		case c.download != "-" && (len(b.ranges) != 1 || b.ranges[0] != c.download):
+			t.Errorf("%s: download requests %q, want %q", c.name, b.ranges, c.download)
+		}
+	}
+	// 没有开启 DownCache 时不缓存到本地
+	if files, _ := ioutil.ReadDir(s.downPath); len(files) != 0 {
+		t.Fatal("unexpected cached files:", len(files))
+	}
+}
+
+func TestDownloadRemoteCache(t *testing.T) {
+	s, _ := newDownloadTestServer(t, true)
+	rec := httptest.NewRecorder()
+	s.download(rec, httptest.NewRequest(http.MethodGet, "/dir/a.txt", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "0123456789" {
+		t.Fatal("unexpected response:", rec.Code, rec.Body.String())
+	}
+
+	// 后台下载完成后临时文件被重命名为正式文件
+	fPath := s.downPath + renameFile("/dir/a.txt")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, err := ioutil.ReadFile(fPath)
+		if err == nil {
+			if string(data) != "0123456789" {
+				t.Fatalf("unexpected cached content %q", data)
+			}
+			break
+		}
+		if !os.IsNotExist(err) || time.Now().After(deadline) {
+			t.Fatal("object was not cached:", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := os.Stat(fPath + ".downloading"); !os.IsNotExist(err) {
+		t.Fatal("temporary file should be renamed:", err)
+	}
+
+	// 之后的请求直接读取本地缓存
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/dir/a.txt", nil)
+	req.Header.Set("Range", "bytes=8-")
+	s.download(rec, req)
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "89" || !strings.HasPrefix(rec.Header().Get("Content-Range"), "bytes 8-9/") {
+		t.Fatal("unexpected cached response:", rec.Code, rec.Body.String(), rec.Header())
+	}
+}
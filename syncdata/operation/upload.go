@@ -4,198 +4,231 @@ import (
 	"bufio"
 	"bytes"
 	"context"
-	"encoding/json"
 	"io"
 	"io/ioutil"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/qiniupd/qiniu-go-sdk/api.v7/auth/qbox"
 	"github.com/qiniupd/qiniu-go-sdk/api.v8/kodo"
 	q "github.com/qiniupd/qiniu-go-sdk/api.v8/kodocli"
+	"github.com/qiniupd/qiniu-go-sdk/x/kvlog.v1"
+	"github.com/qiniupd/qiniu-go-sdk/x/metrics.v1"
 )
 
+var upTransport = &metrics.Transport{Service: "up"}
+
 // 上传器
 type Uploader struct {
 	bucket        string
 	upHosts       []string
-	credentials   *qbox.Mac
+	credentials   qbox.CredentialsProvider
 	partSize      int64
 	upConcurrency int
 	queryer       *Queryer
+
+	manager     *ConfigManager // 不为空时使用 manager 中的最新配置
+	conf        *Config
+	latest      atomic.Value // *Uploader
+	fixedBucket string       // 通过 WithBucket 指定的存储空间，配置变化后仍然使用该存储空间
 }
 
-func (p *Uploader) makeUptoken(policy *kodo.PutPolicy) string {
+func (p *Uploader) makeUptoken(policy *kodo.PutPolicy) (string, error) {
 	var rr = *policy
 	if rr.Expires == 0 {
 		rr.Expires = 3600 + uint32(time.Now().Unix())
 	}
-	b, _ := json.Marshal(&rr)
-	return qbox.SignWithData(p.credentials, b)
+	mac, err := p.credentials.Retrieve()
+	if err != nil {
+		return "", err
+	}
+	return kodo.SignUptoken(mac, &rr)
 }
 
 // 上传内存数据到指定对象中
-func (p *Uploader) UploadData(data []byte, key string) (err error) {
+func (p *Uploader) UploadData(data []byte, key string, opts ...*UploadOptions) (err error) {
+	p = p.current()
 	t := time.Now()
 	defer func() {
-		elog.Info("up time ", key, time.Now().Sub(t))
+		logger.Info(context.Background(), "up time", kvlog.F("key", key), kvlog.F("elapsed", time.Since(t)))
 	}()
 	key = strings.TrimPrefix(key, "/")
-	policy := kodo.PutPolicy{
-		Scope:   p.bucket + ":" + key,
-		Expires: 3600*24 + uint32(time.Now().Unix()),
+	opt, err := uploadOptions(opts)
+	if err != nil {
+		return err
 	}
+	policy := opt.putPolicy(p.bucket, key)
 
-	upToken := p.makeUptoken(&policy)
-
-	upHosts := p.upHosts
-	if p.queryer != nil {
-		if hosts := p.queryer.QueryUpHosts(false); len(hosts) > 0 {
-			upHosts = hosts
-		}
+	upToken, err := p.makeUptoken(&policy)
+	if err != nil {
+		return err
 	}
 
-	var uploader = q.NewUploader(1, &q.UploadConfig{
-		UpHosts:        upHosts,
-		UploadPartSize: p.partSize,
-		Concurrency:    p.upConcurrency,
-	})
+	uploader, err := p.newKodoUploader()
+	if err != nil {
+		return err
+	}
 	for i := 0; i < 3; i++ {
-		err = uploader.Put2(context.Background(), nil, upToken, key, bytes.NewReader(data), int64(len(data)), nil)
+		if i > 0 {
+			recordRetry("up", "form")
+		}
+		err = uploader.Put2(context.Background(), nil, upToken, key, bytes.NewReader(data), int64(len(data)), opt.putExtra())
 		if err == nil {
 			break
 		}
-		elog.Info("small upload retry", i, err)
+		logger.Info(context.Background(), "small upload retry", kvlog.F("key", key), kvlog.F("attempt", i), kvlog.F("error", err))
 	}
 	return
 }
 
 // 从 Reader 中阅读指定大小的数据并上传到指定对象中
-func (p *Uploader) UploadDataReader(data io.ReaderAt, size int, key string) (err error) {
+func (p *Uploader) UploadDataReader(data io.ReaderAt, size int, key string, opts ...*UploadOptions) (err error) {
+	p = p.current()
 	t := time.Now()
 	defer func() {
-		elog.Info("up time ", key, time.Now().Sub(t))
+		logger.Info(context.Background(), "up time", kvlog.F("key", key), kvlog.F("elapsed", time.Since(t)))
 	}()
 	key = strings.TrimPrefix(key, "/")
-	policy := kodo.PutPolicy{
-		Scope:   p.bucket + ":" + key,
-		Expires: 3600*24 + uint32(time.Now().Unix()),
+	opt, err := uploadOptions(opts)
+	if err != nil {
+		return err
 	}
+	policy := opt.putPolicy(p.bucket, key)
 
-	upToken := p.makeUptoken(&policy)
-
-	upHosts := p.upHosts
-	if p.queryer != nil {
-		if hosts := p.queryer.QueryUpHosts(false); len(hosts) > 0 {
-			upHosts = hosts
-		}
+	upToken, err := p.makeUptoken(&policy)
+	if err != nil {
+		return err
 	}
 
-	var uploader = q.NewUploader(1, &q.UploadConfig{
-		UpHosts:        upHosts,
-		UploadPartSize: p.partSize,
-		Concurrency:    p.upConcurrency,
-	})
+	uploader, err := p.newKodoUploader()
+	if err != nil {
+		return err
+	}
 
 	for i := 0; i < 3; i++ {
-		err = uploader.Put2(context.Background(), nil, upToken, key, newReaderAtNopCloser(data), int64(size), nil)
+		if i > 0 {
+			recordRetry("up", "form")
+		}
+		err = uploader.Put2(context.Background(), nil, upToken, key, newReaderAtNopCloser(data), int64(size), opt.putExtra())
 		if err == nil {
 			break
 		}
-		elog.Info("small upload retry", i, err)
+		logger.Info(context.Background(), "small upload retry", kvlog.F("key", key), kvlog.F("attempt", i), kvlog.F("error", err))
 	}
 	return
 }
 
 // 上传指定文件到指定对象中
-func (p *Uploader) Upload(file string, key string) (err error) {
+func (p *Uploader) Upload(file string, key string, opts ...*UploadOptions) (err error) {
+	return p.upload(context.Background(), file, key, nil, nil, opts...)
+}
+
+// upload 上传指定文件，ret 用于接收上传结果，onProgress 在每次有数据上传成功后被调用，参数为已上传的字节数
+func (p *Uploader) upload(ctx context.Context, file string, key string, ret *q.PutRet, onProgress func(uploaded int64), opts ...*UploadOptions) (err error) {
+	p = p.current()
 	t := time.Now()
 	defer func() {
-		elog.Info("up time ", key, time.Now().Sub(t))
+		logger.Info(ctx, "up time", kvlog.F("key", key), kvlog.F("elapsed", time.Since(t)))
 	}()
 	key = strings.TrimPrefix(key, "/")
-	policy := kodo.PutPolicy{
-		Scope:   p.bucket + ":" + key,
-		Expires: 3600*24 + uint32(time.Now().Unix()),
+	opt, err := uploadOptions(opts)
+	if err != nil {
+		return err
+	}
+	policy := opt.putPolicy(p.bucket, key)
+	upToken, err := p.makeUptoken(&policy)
+	if err != nil {
+		return err
 	}
-	upToken := p.makeUptoken(&policy)
 
 	f, err := os.Open(file)
 	if err != nil {
-		elog.Info("open file failed: ", file, err)
+		logger.Info(ctx, "open file failed", kvlog.F("path", file), kvlog.F("error", err))
 		return err
 	}
 	defer f.Close()
 
 	fInfo, err := f.Stat()
 	if err != nil {
-		elog.Info("get file stat failed: ", err)
+		logger.Info(ctx, "get file stat failed", kvlog.F("path", file), kvlog.F("error", err))
 		return err
 	}
 
-	upHosts := p.upHosts
-	if p.queryer != nil {
-		if hosts := p.queryer.QueryUpHosts(false); len(hosts) > 0 {
-			upHosts = hosts
-		}
+	uploader, err := p.newKodoUploader()
+	if err != nil {
+		return err
 	}
 
-	var uploader = q.NewUploader(1, &q.UploadConfig{
-		UpHosts:        upHosts,
-		UploadPartSize: p.partSize,
-		Concurrency:    p.upConcurrency,
-	})
+	var retValue interface{}
+	if ret != nil {
+		retValue = ret
+	}
 
 	if fInfo.Size() <= p.partSize {
 		for i := 0; i < 3; i++ {
-			err = uploader.Put2(context.Background(), nil, upToken, key, newReaderAtNopCloser(f), fInfo.Size(), nil)
-			if err == nil {
+			if i > 0 {
+				recordRetry("up", "form")
+			}
+			err = uploader.Put2(ctx, retValue, upToken, key, newReaderAtNopCloser(f), fInfo.Size(), opt.putExtra())
+			if err == nil || ctx.Err() != nil {
 				break
 			}
-			elog.Info("small upload retry", i, err)
+			logger.Info(ctx, "small upload retry", kvlog.F("key", key), kvlog.F("attempt", i), kvlog.F("error", err))
+		}
+		if err == nil && onProgress != nil {
+			onProgress(fInfo.Size())
 		}
 		return
 	}
 
 	for i := 0; i < 3; i++ {
-		err = uploader.Upload(context.Background(), nil, upToken, key, newReaderAtNopCloser(f), fInfo.Size(), nil,
+		if i > 0 {
+			recordRetry("up", "multipart")
+		}
+		var uploaded int64
+		err = uploader.Upload(ctx, retValue, upToken, key, newReaderAtNopCloser(f), fInfo.Size(), opt.completeMultipart(),
 			func(partIdx int, etag string) {
-				elog.Info("callback", partIdx, etag)
+				logger.Debug(ctx, "part uploaded", kvlog.F("key", key), kvlog.F("part", partIdx), kvlog.F("etag", etag))
+				if onProgress != nil {
+					partSize := p.partSize
+					if rest := fInfo.Size() - int64(partIdx-1)*p.partSize; rest < partSize {
+						partSize = rest
+					}
+					onProgress(atomic.AddInt64(&uploaded, partSize))
+				}
 			})
-		if err == nil {
+		if err == nil || ctx.Err() != nil {
 			break
 		}
-		elog.Info("part upload retry", i, err)
+		logger.Info(ctx, "part upload retry", kvlog.F("key", key), kvlog.F("attempt", i), kvlog.F("error", err))
 	}
 	return
 }
 
 // 从 Reader 中阅读全部数据并上传到指定对象中
-func (p *Uploader) UploadReader(reader io.Reader, key string) (err error) {
+func (p *Uploader) UploadReader(reader io.Reader, key string, opts ...*UploadOptions) (err error) {
+	p = p.current()
 	t := time.Now()
 	defer func() {
-		elog.Info("up time ", key, time.Now().Sub(t))
+		logger.Info(context.Background(), "up time", kvlog.F("key", key), kvlog.F("elapsed", time.Since(t)))
 	}()
 	key = strings.TrimPrefix(key, "/")
-	policy := kodo.PutPolicy{
-		Scope:   p.bucket + ":" + key,
-		Expires: 3600*24 + uint32(time.Now().Unix()),
+	opt, err := uploadOptions(opts)
+	if err != nil {
+		return err
 	}
-	upToken := p.makeUptoken(&policy)
-
-	upHosts := p.upHosts
-	if p.queryer != nil {
-		if hosts := p.queryer.QueryUpHosts(false); len(hosts) > 0 {
-			upHosts = hosts
-		}
+	policy := opt.putPolicy(p.bucket, key)
+	upToken, err := p.makeUptoken(&policy)
+	if err != nil {
+		return err
 	}
 
-	var uploader = q.NewUploader(1, &q.UploadConfig{
-		UpHosts:        upHosts,
-		UploadPartSize: p.partSize,
-		Concurrency:    p.upConcurrency,
-	})
+	uploader, err := p.newKodoUploader()
+	if err != nil {
+		return err
+	}
 
 	bufReader := bufio.NewReader(reader)
 	firstPart, err := ioutil.ReadAll(io.LimitReader(bufReader, p.partSize))
@@ -216,25 +249,47 @@ func (p *Uploader) UploadReader(reader io.Reader, key string) (err error) {
 
 	if smallUpload {
 		for i := 0; i < 3; i++ {
-			err = uploader.Put2(context.Background(), nil, upToken, key, bytes.NewReader(firstPart), int64(len(firstPart)), nil)
+			if i > 0 {
+				recordRetry("up", "form")
+			}
+			err = uploader.Put2(context.Background(), nil, upToken, key, bytes.NewReader(firstPart), int64(len(firstPart)), opt.putExtra())
 			if err == nil {
 				break
 			}
-			elog.Info("small upload retry", i, err)
+			logger.Info(context.Background(), "small upload retry", kvlog.F("key", key), kvlog.F("attempt", i), kvlog.F("error", err))
 		}
 		return
 	}
 
-	err = uploader.StreamUpload(context.Background(), nil, upToken, key, io.MultiReader(bytes.NewReader(firstPart), bufReader),
+	err = uploader.StreamUploadWithMultipart(context.Background(), nil, upToken, key, io.MultiReader(bytes.NewReader(firstPart), bufReader), opt.completeMultipart(),
 		func(partIdx int, etag string) {
-			elog.Info("callback", partIdx, etag)
+			logger.Debug(context.Background(), "part uploaded", kvlog.F("key", key), kvlog.F("part", partIdx), kvlog.F("etag", etag))
 		})
 	return err
 }
 
+// newKodoUploader 使用当前可用的上传域名创建分片上传器，没有可用域名时返回 ErrNoHosts
+func (p *Uploader) newKodoUploader() (*q.Uploader, error) {
+	upHosts := p.upHosts
+	if p.queryer != nil {
+		if hosts := p.queryer.QueryUpHosts(false); len(hosts) > 0 {
+			upHosts = hosts
+		}
+	}
+	if len(upHosts) == 0 {
+		return nil, errNoHosts("up")
+	}
+	uploader := q.NewUploader(1, &q.UploadConfig{
+		UpHosts:        upHosts,
+		Transport:      upTransport,
+		UploadPartSize: p.partSize,
+		Concurrency:    p.upConcurrency,
+	})
+	return &uploader, nil
+}
+
 // 根据配置创建上传器
 func NewUploader(c *Config) *Uploader {
-	mac := qbox.NewMac(c.Ak, c.Sk)
 	part := c.PartSize * 1024 * 1024
 	if part < 4*1024*1024 {
 		part = 4 * 1024 * 1024
@@ -248,20 +303,43 @@ func NewUploader(c *Config) *Uploader {
 	return &Uploader{
 		bucket:        c.Bucket,
 		upHosts:       dupStrings(c.UpHosts),
-		credentials:   mac,
+		credentials:   newCredentials(c),
 		partSize:      part,
 		upConcurrency: c.UpConcurrency,
 		queryer:       queryer,
 	}
 }
 
-// 根据环境变量创建上传器
+// 校验配置后创建上传器，配置不合法或者 uc_hosts 和 up_hosts 都为空时返回 *ConfigError
+func NewUploaderE(c *Config) (*Uploader, error) {
+	if err := c.validateUpload(); err != nil {
+		return nil, err
+	}
+	return NewUploader(c), nil
+}
+
+// 返回上传到指定存储空间的上传器，与 p 共享其他配置和域名查询缓存
+func (p *Uploader) WithBucket(bucket string) *Uploader {
+	return &Uploader{
+		bucket:        bucket,
+		upHosts:       p.upHosts,
+		credentials:   p.credentials,
+		partSize:      p.partSize,
+		upConcurrency: p.upConcurrency,
+		queryer:       p.queryer.WithBucket(bucket),
+		manager:       p.manager,
+		conf:          p.conf,
+		fixedBucket:   bucket,
+	}
+}
+
+// 根据环境变量创建上传器，配置文件变化后自动使用新的配置
 func NewUploaderV2() *Uploader {
-	c := getConf()
-	if c == nil {
+	m := getManager()
+	if m == nil {
 		return nil
 	}
-	return NewUploader(c)
+	return m.NewUploader()
 }
 
 type readerAtCloser interface {
@@ -280,3 +358,21 @@ func (readerAtNopCloser) Close() error { return nil }
 func newReaderAtNopCloser(r io.ReaderAt) readerAtCloser {
 	return readerAtNopCloser{r}
 }
+
+// current 返回使用最新配置的上传器，配置没有变化时返回自身
+func (p *Uploader) current() *Uploader {
+	if p.manager == nil {
+		return p
+	}
+	c := p.manager.Config()
+	if c == p.conf {
+		return p
+	}
+	if latest, ok := p.latest.Load().(*Uploader); ok && latest.conf == c {
+		return latest
+	}
+	latest := NewUploader(configWithBucket(c, p.fixedBucket))
+	latest.conf = c
+	p.latest.Store(latest)
+	return latest
+}
@@ -0,0 +1,117 @@
+package operation
+
+import (
+	"errors"
+	"time"
+
+	"github.com/qiniupd/qiniu-go-sdk/api.v8/kodo"
+	q "github.com/qiniupd/qiniu-go-sdk/api.v8/kodocli"
+)
+
+// 上传选项，前半部分为上传策略，后半部分为对象的元信息。
+// Upload* 方法的 opts 中最多只能有一个不为空的选项，否则返回 ErrTooManyUploadOptions；选项组合不合法（例如设置了 CallbackBody 但没有 CallbackUrl）时
+// Upload* 在上传前返回 kodo.ErrInvalidPutPolicy，可以用 Validate 提前检查
+type UploadOptions struct {
+	InsertOnly          bool          // 仅允许新增，对象已存在时上传失败
+	DeleteAfterDays     int           // 上传后经过指定天数自动删除
+	FileType            kodo.FileType // 存储类型，0 为标准存储，1 为低频存储，2 为归档存储，3 为深度归档存储
+	DetectMime          bool          // 由服务端根据内容判断 MimeType
+	MimeLimit           string
+	FsizeLimit          int64
+	Checksum            string // 格式为 <HashName>:<HexHashValue>，支持 MD5 和 SHA1
+	PersistentOps       string
+	PersistentNotifyUrl string
+	PersistentPipeline  string
+	CallbackUrl         string
+	CallbackHost        string
+	CallbackBody        string
+	CallbackBodyType    string
+	ReturnBody          string
+	Expires             time.Duration // 上传凭证的有效期，为 0 时为 24 小时
+
+	MimeType   string
+	Metadata   map[string]string // 自定义元信息，key 不需要 x-qn-meta- 前缀
+	CustomVars map[string]string // 自定义变量，key 需要以 x: 开头
+}
+
+const defaultUptokenExpires = 24 * time.Hour
+
+var ErrTooManyUploadOptions = errors.New("more than one upload options is given")
+
+// 检查选项能否生成合法的上传策略，错误为 kodo.ErrInvalidPutPolicy
+func (o *UploadOptions) Validate() error {
+	// 只检查选项之间的组合，bucket 和 key 不影响结果
+	policy := o.putPolicy("bucket", "key")
+	return policy.Validate()
+}
+
+// uploadOptions 返回 opts 中唯一不为空的选项，有多个时返回 ErrTooManyUploadOptions
+func uploadOptions(opts []*UploadOptions) (opt *UploadOptions, err error) {
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		if opt != nil {
+			return nil, ErrTooManyUploadOptions
+		}
+		opt = o
+	}
+	return opt, nil
+}
+
+func (o *UploadOptions) putPolicy(bucket, key string) kodo.PutPolicy {
+	expires := defaultUptokenExpires
+	if o != nil && o.Expires > 0 {
+		expires = o.Expires
+	}
+	policy := kodo.PutPolicy{
+		Scope:   bucket + ":" + key,
+		Expires: uint32(time.Now().Add(expires).Unix()),
+	}
+	if o == nil {
+		return policy
+	}
+	if o.InsertOnly {
+		policy.InsertOnly = 1
+	}
+	if o.DetectMime {
+		policy.DetectMime = 1
+	}
+	policy.DeleteAfterDays = o.DeleteAfterDays
+	policy.FileType = o.FileType
+	policy.MimeLimit = o.MimeLimit
+	policy.FsizeLimit = o.FsizeLimit
+	policy.Checksum = o.Checksum
+	policy.PersistentOps = o.PersistentOps
+	policy.PersistentNotifyUrl = o.PersistentNotifyUrl
+	policy.PersistentPipeline = o.PersistentPipeline
+	policy.CallbackUrl = o.CallbackUrl
+	policy.CallbackHost = o.CallbackHost
+	policy.CallbackBody = o.CallbackBody
+	policy.CallbackBodyType = o.CallbackBodyType
+	policy.ReturnBody = o.ReturnBody
+	return policy
+}
+
+func (o *UploadOptions) putExtra() *q.PutExtra {
+	if o == nil {
+		return nil
+	}
+	return &q.PutExtra{
+		Params:   o.CustomVars,
+		XMeta:    o.Metadata,
+		MimeType: o.MimeType,
+	}
+}
+
+// completeMultipart 每次分片上传都需要新的 CompleteMultipart，kodocli 会修改其中的 Parts
+func (o *UploadOptions) completeMultipart() *q.CompleteMultipart {
+	if o == nil {
+		return nil
+	}
+	return &q.CompleteMultipart{
+		MimeType:   o.MimeType,
+		Metadata:   o.Metadata,
+		CustomVars: o.CustomVars,
+	}
+}
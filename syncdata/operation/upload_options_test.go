@@ -0,0 +1,181 @@
+package operation
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiniupd/qiniu-go-sdk/api.v8/kodo"
+)
+
+func TestUploadOptionsValidate(t *testing.T) {
+	cases := []struct {
+		name  string
+		opts  *UploadOptions
+		valid bool
+	}{
+		{name: "nil", valid: true},
+		{name: "callback", opts: &UploadOptions{CallbackUrl: "http://example.com/cb", CallbackBody: "key=$(key)"}, valid: true},
+		{name: "callback body without url", opts: &UploadOptions{CallbackBody: "key=$(key)"}},
+		{name: "callback url without body", opts: &UploadOptions{CallbackUrl: "http://example.com/cb"}},
+		{name: "bad callback body type", opts: &UploadOptions{CallbackUrl: "http://example.com/cb", CallbackBody: "k", CallbackBodyType: "text/plain"}},
+		{name: "notify without persistent ops", opts: &UploadOptions{PersistentNotifyUrl: "http://example.com/n"}},
+	}
+	for _, c := range cases {
+		err := c.opts.Validate()
+		if c.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+		if !c.valid && !errors.Is(err, kodo.ErrInvalidPutPolicy) {
+			t.Errorf("%s: error %v, want ErrInvalidPutPolicy", c.name, err)
+		}
+	}
+}
+
+func TestUploadOptionsPutPolicy(t *testing.T) {
+	before := time.Now()
+	policy := (*UploadOptions)(nil).putPolicy("b", "a.car")
+	if policy.Scope != "b:a.car" || int64(policy.Expires)-before.Add(defaultUptokenExpires).Unix() > 1 {
+		t.Fatalf("unexpected default policy: %+v", policy)
+	}
+
+	o := &UploadOptions{
+		InsertOnly:          true,
+		DeleteAfterDays:     7,
+		FileType:            kodo.TypeLine,
+		DetectMime:          true,
+		MimeLimit:           "image/*",
+		FsizeLimit:          1 << 20,
+		Checksum:            "MD5:abc",
+		PersistentOps:       "avthumb/mp4",
+		PersistentNotifyUrl: "http://example.com/n",
+		PersistentPipeline:  "p1",
+		CallbackUrl:         "http://example.com/cb",
+		CallbackHost:        "cb.example.com",
+		CallbackBody:        `{"key":"$(key)"}`,
+		CallbackBodyType:    "application/json",
+		ReturnBody:          `{"hash":"$(etag)"}`,
+		Expires:             time.Hour,
+	}
+	policy = o.putPolicy("b", "a.car")
+	want := kodo.PutPolicy{
+		Scope:               "b:a.car",
+		Expires:             policy.Expires,
+		InsertOnly:          1,
+		DetectMime:          1,
+		DeleteAfterDays:     7,
+		FileType:            kodo.TypeLine,
+		MimeLimit:           "image/*",
+		FsizeLimit:          1 << 20,
+		Checksum:            "MD5:abc",
+		PersistentOps:       "avthumb/mp4",
+		PersistentNotifyUrl: "http://example.com/n",
+		PersistentPipeline:  "p1",
+		CallbackUrl:         "http://example.com/cb",
+		CallbackHost:        "cb.example.com",
+		CallbackBody:        `{"key":"$(key)"}`,
+		CallbackBodyType:    "application/json",
+		ReturnBody:          `{"hash":"$(etag)"}`,
+	}
+	if !reflect.DeepEqual(policy, want) {
+		t.Fatalf("unexpected policy:\n%+v\nwant\n%+v", policy, want)
+	}
+	if d := int64(policy.Expires) - before.Add(time.Hour).Unix(); d < 0 || d > 1 {
+		t.Fatal("Expires should be relative to now:", d)
+	}
+}
+
+func TestUploadOptionsMetadata(t *testing.T) {
+	var nilOpts *UploadOptions
+	if nilOpts.putExtra() != nil || nilOpts.completeMultipart() != nil {
+		t.Fatal("nil options should not set extra or multipart")
+	}
+
+	o := &UploadOptions{
+		MimeType:   "application/octet-stream",
+		Metadata:   map[string]string{"owner": "f01234"},
+		CustomVars: map[string]string{"x:sector": "1"},
+	}
+	extra := o.putExtra()
+	if extra.MimeType != o.MimeType || !reflect.DeepEqual(extra.XMeta, o.Metadata) || !reflect.DeepEqual(extra.Params, o.CustomVars) {
+		t.Fatalf("unexpected put extra: %+v", extra)
+	}
+	mp1, mp2 := o.completeMultipart(), o.completeMultipart()
+	if mp1 == mp2 {
+		t.Fatal("completeMultipart should return a new value each time")
+	}
+	if mp1.MimeType != o.MimeType || !reflect.DeepEqual(mp1.Metadata, o.Metadata) || !reflect.DeepEqual(mp1.CustomVars, o.CustomVars) {
+		t.Fatalf("unexpected complete multipart: %+v", mp1)
+	}
+}
+
+func TestUploadDataMetadataOrder(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		paths = append(paths, req.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"key":"a.car","hash":"h"}`))
+	}))
+	defer srv.Close()
+
+	up := NewUploader(&Config{Ak: "ak", Sk: "sk", Bucket: "b", UpHosts: []string{srv.URL}})
+	o := &UploadOptions{
+		Metadata:   map[string]string{"c": "3", "a": "1", "b": "2", "e": "5", "d": "4"},
+		CustomVars: map[string]string{"x:z": "26", "x:y": "25"},
+	}
+	for i := 0; i < 5; i++ {
+		if err := up.UploadData([]byte("data"), "a.car", o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	enc := func(v string) string { return base64.URLEncoding.EncodeToString([]byte(v)) }
+	want := "/x:y/" + enc("25") + "/x:z/" + enc("26") +
+		"/x-qn-meta-a/" + enc("1") + "/x-qn-meta-b/" + enc("2") + "/x-qn-meta-c/" + enc("3") +
+		"/x-qn-meta-d/" + enc("4") + "/x-qn-meta-e/" + enc("5")
+	if len(paths) != 5 {
+		t.Fatal("unexpected upload requests:", paths)
+	}
+	for _, p := range paths {
+		if !strings.Contains(p, want) {
+			t.Fatalf("upload url %s should contain sorted custom vars and metadata %s", p, want)
+		}
+	}
+}
+
+func TestUploadOptionsMultiple(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"key":"a.car","hash":"h"}`))
+	}))
+	defer srv.Close()
+
+	up := NewUploader(&Config{Ak: "ak", Sk: "sk", Bucket: "b", UpHosts: []string{srv.URL}})
+	o := &UploadOptions{MimeType: "text/plain"}
+	cases := []struct {
+		name string
+		opts []*UploadOptions
+		err  error
+	}{
+		{name: "none"},
+		{name: "nil options are skipped", opts: []*UploadOptions{nil, o, nil}},
+		{name: "two options", opts: []*UploadOptions{o, {InsertOnly: true}}, err: ErrTooManyUploadOptions},
+	}
+	for _, c := range cases {
+		requests = 0
+		err := up.UploadData([]byte("data"), "a.car", c.opts...)
+		if err != c.err {
+			t.Errorf("%s: error %v, want %v", c.name, err, c.err)
+		}
+		// 选项不合法时不发出上传请求
+		if (c.err == nil) != (requests == 1) {
+			t.Errorf("%s: unexpected upload requests %d", c.name, requests)
+		}
+	}
+}
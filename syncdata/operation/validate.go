@@ -0,0 +1,142 @@
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/conf"
+)
+
+// 配置中没有可用的服务域名
+var ErrNoHosts = errors.New("no hosts is configured")
+
+func errNoHosts(service string) error {
+	return fmt.Errorf("%w: %s", ErrNoHosts, service)
+}
+
+const (
+	maxPartSize    = 1024 // 分片大小上限，单位 MB
+	maxBatchSize   = 1000 // 单次 batch 操作的对象数上限
+	maxRestoreDays = 7    // 归档存储对象解冻后可以下载的天数上限
+)
+
+// 配置校验错误，包含所有不合法的配置项
+type ConfigError struct {
+	Errors []error
+}
+
+func (e *ConfigError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return "invalid config: " + strings.Join(msgs, "; ")
+}
+
+func (e *ConfigError) add(format string, args ...interface{}) {
+	e.Errors = append(e.Errors, fmt.Errorf(format, args...))
+}
+
+// 校验配置，返回的错误为 *ConfigError，其中包含所有不合法的配置项。
+// 数值类配置为 0 时使用默认值；ak 和 sk 都为空时与 qbox.NewMac 一样使用 conf.ACCESS_KEY 和 conf.SECRET_KEY；
+// 模拟模式下不要求配置密钥。上传域名只在创建上传器时检查，见 NewUploaderE
+func (c *Config) Validate() error {
+	e := &ConfigError{}
+	if !c.Sim {
+		if c.Credentials == nil && c.CredentialsFile == "" {
+			if c.Ak == "" && c.Sk == "" {
+				if conf.ACCESS_KEY == "" {
+					e.add("ak and sk are required")
+				}
+			} else if c.Ak == "" || c.Sk == "" {
+				e.add("ak and sk must be set together")
+			}
+		}
+		if c.Bucket == "" {
+			e.add("bucket is required")
+		}
+	}
+	validateHosts(e, "up_hosts", c.UpHosts)
+	validateHosts(e, "rs_hosts", c.RsHosts)
+	validateHosts(e, "rsf_hosts", c.RsfHosts)
+	validateHosts(e, "io_hosts", c.IoHosts)
+	validateHosts(e, "uc_hosts", c.UcHosts)
+	validateHosts(e, "api_hosts", c.ApiHosts)
+
+	if c.PartSize < 0 || c.PartSize > maxPartSize {
+		e.add("part must be between 0 and %d (MB), got %d", maxPartSize, c.PartSize)
+	}
+	if c.UpConcurrency < 0 {
+		e.add("up_concurrency must not be negative, got %d", c.UpConcurrency)
+	}
+	if c.BatchConcurrency < 0 {
+		e.add("batch_concurrency must not be negative, got %d", c.BatchConcurrency)
+	}
+	if c.BatchSize < 0 || c.BatchSize > maxBatchSize {
+		e.add("batch_size must be between 0 and %d, got %d", maxBatchSize, c.BatchSize)
+	}
+	if c.JobWorkers < 0 {
+		e.add("job_workers must not be negative, got %d", c.JobWorkers)
+	}
+	if c.JobQueueSize < 0 {
+		e.add("job_queue_size must not be negative, got %d", c.JobQueueSize)
+	}
+	if c.RestoreDays < 0 || c.RestoreDays > maxRestoreDays {
+		e.add("restore_days must be between 0 and %d, got %d", maxRestoreDays, c.RestoreDays)
+	}
+	if c.RestoreTimeout < 0 {
+		e.add("restore_timeout must not be negative, got %d", c.RestoreTimeout)
+	}
+
+	validateKeyPrefix(e, c)
+
+	if (c.AuthAk == "") != (c.AuthSk == "") {
+		e.add("auth_ak and auth_sk must be set together")
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		e.add("tls_cert_file and tls_key_file must be set together")
+	}
+	if len(e.Errors) > 0 {
+		return e
+	}
+	return nil
+}
+
+// validateUpload 在 Validate 的基础上检查上传需要的域名配置
+func (c *Config) validateUpload() error {
+	err := c.Validate()
+	if c.Sim || len(c.UcHosts) > 0 || len(c.UpHosts) > 0 {
+		return err
+	}
+	e, ok := err.(*ConfigError)
+	if !ok {
+		e = &ConfigError{}
+	}
+	e.add("either uc_hosts or up_hosts is required for uploading")
+	return e
+}
+
+// validateHosts 检查域名是否为 http(s)://host[:port] 形式，也支持 "-H <host> <url>" 指定 Host 的形式
+func validateHosts(e *ConfigError, name string, hosts []string) {
+	for _, host := range hosts {
+		rawURL := host
+		if strings.HasPrefix(host, "-H") {
+			fields := strings.Fields(host)
+			if len(fields) != 3 {
+				e.add("%s: invalid host %q", name, host)
+				continue
+			}
+			rawURL = fields[2]
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			e.add("%s: invalid host %q: %v", name, host, err)
+			continue
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			e.add("%s: host %q must be an http or https url", name, host)
+		}
+	}
+}
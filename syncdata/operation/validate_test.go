@@ -0,0 +1,67 @@
+package operation
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/qiniupd/qiniu-go-sdk/api.v7/conf"
+)
+
+func TestValidate(t *testing.T) {
+	defer func(ak, sk string) { conf.ACCESS_KEY, conf.SECRET_KEY = ak, sk }(conf.ACCESS_KEY, conf.SECRET_KEY)
+
+	cases := []struct {
+		name      string
+		globalKey string
+		cfg       Config
+		err       string // 为空时期望校验通过
+	}{
+		{name: "download only", cfg: Config{Ak: "ak", Sk: "sk", Bucket: "b", IoHosts: []string{"http://io.example.com"}}},
+		{name: "global keys", globalKey: "gak", cfg: Config{Bucket: "b", IoHosts: []string{"http://io.example.com"}}},
+		{name: "no keys", cfg: Config{Bucket: "b"}, err: "ak and sk are required"},
+		{name: "ak without sk", globalKey: "gak", cfg: Config{Ak: "ak", Bucket: "b"}, err: "ak and sk must be set together"},
+		{name: "no bucket", cfg: Config{Ak: "ak", Sk: "sk"}, err: "bucket is required"},
+		{name: "bad host", cfg: Config{Ak: "ak", Sk: "sk", Bucket: "b", RsHosts: []string{"rs.example.com"}}, err: "rs_hosts"},
+		{name: "sim", cfg: Config{Sim: true}},
+	}
+	for _, c := range cases {
+		conf.ACCESS_KEY, conf.SECRET_KEY = c.globalKey, c.globalKey
+		err := c.cfg.Validate()
+		if c.err == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", c.name, err)
+			}
+			continue
+		}
+		var ce *ConfigError
+		if !errors.As(err, &ce) || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: error %v, want %q", c.name, err, c.err)
+		}
+	}
+}
+
+func TestValidateUpload(t *testing.T) {
+	c := &Config{Ak: "ak", Sk: "sk", Bucket: "b", IoHosts: []string{"http://io.example.com"}}
+	if _, err := NewUploaderE(c); err == nil || !strings.Contains(err.Error(), "uc_hosts or up_hosts") {
+		t.Fatal("NewUploaderE without uc_hosts and up_hosts:", err)
+	}
+	c.UpHosts = []string{"http://up.example.com"}
+	if _, err := NewUploaderE(c); err != nil {
+		t.Fatal("NewUploaderE:", err)
+	}
+}
+
+func TestStartServerListenError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c := &Config{Sim: true, Addr: ln.Addr().String(), DisableUpload: true}
+	if srv, err := StartServer(c); err == nil {
+		srv.Close()
+		t.Fatal("StartServer should fail when the address is in use")
+	}
+}
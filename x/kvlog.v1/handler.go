@@ -0,0 +1,206 @@
+package kvlog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+type discard struct{}
+
+func (discard) Enabled(Level) bool             { return false }
+func (discard) Handle(context.Context, Record) {}
+
+// 丢弃所有日志的 Handler
+var Discard Handler = discard{}
+
+// --------------------------------------------------------------------
+
+// 以文本格式输出日志的 Handler，格式为：
+//
+//	2006/01/02 15:04:05.000000 [INFO] msg key1=value1 key2="value 2"
+type TextHandler struct {
+	lock  sync.Mutex
+	w     io.Writer
+	level Level
+}
+
+// 创建输出 level 及以上级别日志的 TextHandler
+func NewTextHandler(w io.Writer, level Level) *TextHandler {
+	return &TextHandler{w: w, level: level}
+}
+
+func (h *TextHandler) Enabled(level Level) bool {
+	return level >= h.level
+}
+
+func (h *TextHandler) Handle(ctx context.Context, r Record) {
+	var b strings.Builder
+	b.WriteString(r.Time.Format("2006/01/02 15:04:05.000000"))
+	b.WriteString(" [")
+	b.WriteString(r.Level.String())
+	b.WriteString("] ")
+	b.WriteString(r.Msg)
+	for _, f := range r.Fields {
+		b.WriteByte(' ')
+		b.WriteString(f.Key)
+		b.WriteByte('=')
+		b.WriteString(quoteIfNeeded(formatValue(f.Value)))
+	}
+	b.WriteByte('\n')
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	io.WriteString(h.w, b.String())
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "<nil>"
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
+
+func quoteIfNeeded(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, c := range s {
+		if c == '"' || c == '=' || unicode.IsSpace(c) || !unicode.IsPrint(c) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+// --------------------------------------------------------------------
+
+// Printer 是只支持可变参数的分级日志接口，kodocli.Ilog 和 gas/logger.Logger 都满足该接口
+type Printer interface {
+	Debug(v ...interface{})
+	Info(v ...interface{})
+	Warn(v ...interface{})
+	Error(v ...interface{})
+}
+
+type printerHandler struct {
+	p Printer
+}
+
+// 将日志输出到 Printer 的 Handler，字段以 key=value 形式拼接在消息之后
+func FromPrinter(p Printer) Handler {
+	return printerHandler{p}
+}
+
+func (h printerHandler) Enabled(Level) bool { return true }
+
+func (h printerHandler) Handle(ctx context.Context, r Record) {
+	v := make([]interface{}, 0, len(r.Fields)+1)
+	v = append(v, r.Msg)
+	for _, f := range r.Fields {
+		v = append(v, f.Key+"="+quoteIfNeeded(formatValue(f.Value)))
+	}
+	switch {
+	case r.Level >= LevelError:
+		h.p.Error(v...)
+	case r.Level >= LevelWarn:
+		h.p.Warn(v...)
+	case r.Level >= LevelInfo:
+		h.p.Info(v...)
+	default:
+		h.p.Debug(v...)
+	}
+}
+
+// 将 Logger 包装为 Printer，用于只接受可变参数日志接口的地方，如 kodocli.SetLogger。
+// 可变参数以空格拼接作为日志消息
+func ToPrinter(l *Logger) *PrinterLogger {
+	return &PrinterLogger{l: l, ctx: context.Background()}
+}
+
+// 由 ToPrinter 返回，同时满足 kodocli.Ilog 接口。
+// 第一个参数为 context.Context 时，使用它输出日志（从而带上其中的 reqid），且不作为消息的一部分
+type PrinterLogger struct {
+	l   *Logger
+	ctx context.Context
+}
+
+// 返回一个新的 PrinterLogger，没有传入 context.Context 参数的日志使用 ctx 输出
+func (p *PrinterLogger) WithContext(ctx context.Context) *PrinterLogger {
+	return &PrinterLogger{l: p.l, ctx: ctx}
+}
+
+func (p *PrinterLogger) Debug(v ...interface{}) { p.log(LevelDebug, v) }
+func (p *PrinterLogger) Info(v ...interface{})  { p.log(LevelInfo, v) }
+func (p *PrinterLogger) Warn(v ...interface{})  { p.log(LevelWarn, v) }
+func (p *PrinterLogger) Error(v ...interface{}) { p.log(LevelError, v) }
+
+// Fatal 以 Error 级别输出日志后退出进程
+func (p *PrinterLogger) Fatal(v ...interface{}) {
+	p.log(LevelError, v)
+	os.Exit(1)
+}
+
+func (p *PrinterLogger) log(level Level, v []interface{}) {
+	if !p.l.Enabled(level) {
+		return
+	}
+	ctx := p.ctx
+	if len(v) > 0 {
+		if c, ok := v[0].(context.Context); ok {
+			ctx, v = c, v[1:]
+		}
+	}
+	p.l.Log(ctx, level, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+}
+
+// --------------------------------------------------------------------
+
+// SlogLogger 是 log/slog 风格的日志接口，*slog.Logger 满足该接口
+type SlogLogger interface {
+	DebugContext(ctx context.Context, msg string, args ...interface{})
+	InfoContext(ctx context.Context, msg string, args ...interface{})
+	WarnContext(ctx context.Context, msg string, args ...interface{})
+	ErrorContext(ctx context.Context, msg string, args ...interface{})
+}
+
+type slogHandler struct {
+	l SlogLogger
+}
+
+// 将日志输出到 log/slog 风格日志接口的 Handler，字段以交替的键值参数传递，
+// 级别过滤由后端自行处理
+func FromSlog(l SlogLogger) Handler {
+	return slogHandler{l}
+}
+
+func (h slogHandler) Enabled(Level) bool { return true }
+
+func (h slogHandler) Handle(ctx context.Context, r Record) {
+	args := make([]interface{}, 0, len(r.Fields)*2)
+	for _, f := range r.Fields {
+		args = append(args, f.Key, f.Value)
+	}
+	switch {
+	case r.Level >= LevelError:
+		h.l.ErrorContext(ctx, r.Msg, args...)
+	case r.Level >= LevelWarn:
+		h.l.WarnContext(ctx, r.Msg, args...)
+	case r.Level >= LevelInfo:
+		h.l.InfoContext(ctx, r.Msg, args...)
+	default:
+		h.l.DebugContext(ctx, r.Msg, args...)
+	}
+}
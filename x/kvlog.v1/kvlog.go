@@ -0,0 +1,139 @@
+/*
+包 github.com/qiniupd/qiniu-go-sdk/x/kvlog.v1 提供带键值对字段的分级结构化日志。
+
+Logger 负责组装日志记录，Handler 负责输出。SDK 提供以下 Handler：
+
+	NewTextHandler(w, level) // 以 key=value 文本格式输出
+	FromPrinter(p)           // 输出到 kodocli.Ilog 等只支持可变参数的日志接口
+	FromSlog(l)              // 输出到 *slog.Logger 等 log/slog 风格的日志接口
+	Discard                  // 丢弃所有日志
+
+如果 Context 中有 xlog.Logger，日志会自动带上 reqid 字段：
+
+	logger := kvlog.New(kvlog.NewTextHandler(os.Stderr, kvlog.LevelInfo))
+	logger.Info(ctx, "upload done", kvlog.F("key", key), kvlog.F("size", size))
+*/
+package kvlog
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/qiniupd/qiniu-go-sdk/x/xlog.v8"
+)
+
+// 日志级别
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return "LEVEL(" + strconv.Itoa(int(l)) + ")"
+}
+
+// 日志字段
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// 创建日志字段
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// 一条日志记录
+type Record struct {
+	Time   time.Time
+	Level  Level
+	Msg    string
+	Fields []Field
+}
+
+// Handler 负责输出日志记录，实现需要支持并发调用
+type Handler interface {
+	// 是否输出指定级别的日志，返回 false 时 Logger 不会组装日志记录
+	Enabled(level Level) bool
+	Handle(ctx context.Context, r Record)
+}
+
+// 结构化日志记录器
+type Logger struct {
+	h      Handler
+	fields []Field
+}
+
+// 创建使用指定 Handler 的 Logger，h 为 nil 时丢弃所有日志
+func New(h Handler) *Logger {
+	if h == nil {
+		h = Discard
+	}
+	return &Logger{h: h}
+}
+
+// 返回 Logger 使用的 Handler
+func (l *Logger) Handler() Handler {
+	return l.h
+}
+
+// 返回一个新的 Logger，其输出的每条日志都带有指定字段
+func (l *Logger) With(fields ...Field) *Logger {
+	all := make([]Field, 0, len(l.fields)+len(fields))
+	all = append(all, l.fields...)
+	all = append(all, fields...)
+	return &Logger{h: l.h, fields: all}
+}
+
+// 是否输出指定级别的日志
+func (l *Logger) Enabled(level Level) bool {
+	return l.h.Enabled(level)
+}
+
+func (l *Logger) Debug(ctx context.Context, msg string, fields ...Field) {
+	l.Log(ctx, LevelDebug, msg, fields...)
+}
+
+func (l *Logger) Info(ctx context.Context, msg string, fields ...Field) {
+	l.Log(ctx, LevelInfo, msg, fields...)
+}
+
+func (l *Logger) Warn(ctx context.Context, msg string, fields ...Field) {
+	l.Log(ctx, LevelWarn, msg, fields...)
+}
+
+func (l *Logger) Error(ctx context.Context, msg string, fields ...Field) {
+	l.Log(ctx, LevelError, msg, fields...)
+}
+
+// 输出一条日志，ctx 可以为 nil
+func (l *Logger) Log(ctx context.Context, level Level, msg string, fields ...Field) {
+	if !l.h.Enabled(level) {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	all := make([]Field, 0, len(l.fields)+len(fields)+1)
+	if xl, ok := xlog.FromContext(ctx); ok {
+		all = append(all, F("reqid", xl.ReqId()))
+	}
+	all = append(all, l.fields...)
+	all = append(all, fields...)
+	l.h.Handle(ctx, Record{Time: time.Now(), Level: level, Msg: msg, Fields: all})
+}
@@ -0,0 +1,89 @@
+package kvlog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/qiniupd/qiniu-go-sdk/x/xlog.v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextHandler(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(NewTextHandler(&buf, LevelInfo)).With(F("bucket", "b"))
+	ctx := xlog.NewContext(context.Background(), xlog.NewWith("reqid1"))
+
+	l.Debug(ctx, "ignored")
+	l.Info(ctx, "upload done", F("key", "a b"), F("size", 10), F("err", errors.New("x")), F("nil", nil))
+	line := buf.String()
+	assert.Contains(t, line, ` [INFO] upload done reqid=reqid1 bucket=b key="a b" size=10 err=x nil=<nil>`+"\n")
+	assert.Equal(t, 1, strings.Count(line, "\n"))
+}
+
+type printer struct {
+	lines []string
+}
+
+func (p *printer) Debug(v ...interface{}) {
+	p.lines = append(p.lines, "D "+strings.TrimSpace(fmt.Sprintln(v...)))
+}
+func (p *printer) Info(v ...interface{}) {
+	p.lines = append(p.lines, "I "+strings.TrimSpace(fmt.Sprintln(v...)))
+}
+func (p *printer) Warn(v ...interface{}) {
+	p.lines = append(p.lines, "W "+strings.TrimSpace(fmt.Sprintln(v...)))
+}
+func (p *printer) Error(v ...interface{}) {
+	p.lines = append(p.lines, "E "+strings.TrimSpace(fmt.Sprintln(v...)))
+}
+
+func TestPrinter(t *testing.T) {
+	p := &printer{}
+	l := New(FromPrinter(p))
+	l.Warn(nil, "retry", F("host", "h"))
+	l.Debug(nil, "d")
+	assert.Equal(t, []string{"W retry host=h", "D d"}, p.lines)
+
+	var buf bytes.Buffer
+	ToPrinter(New(NewTextHandler(&buf, LevelWarn))).Warn("stat retry", 0, "host")
+	assert.Contains(t, buf.String(), "[WARN] stat retry 0 host\n")
+
+	// 调用方传入的 ctx 中的 reqid 不会丢失
+	buf.Reset()
+	pl := ToPrinter(New(NewTextHandler(&buf, LevelInfo)))
+	ctx := xlog.NewContext(context.Background(), xlog.NewWith("reqid2"))
+	pl.Info(ctx, "put", "a")
+	pl.WithContext(ctx).Warn("retry")
+	assert.Contains(t, buf.String(), "[INFO] put a reqid=reqid2\n")
+	assert.Contains(t, buf.String(), "[WARN] retry reqid=reqid2\n")
+}
+
+type slogger struct {
+	calls []string
+}
+
+func (s *slogger) log(level, msg string, args []interface{}) {
+	s.calls = append(s.calls, level+" "+msg+" "+fmt.Sprint(args...))
+}
+func (s *slogger) DebugContext(ctx context.Context, msg string, args ...interface{}) {
+	s.log("D", msg, args)
+}
+func (s *slogger) InfoContext(ctx context.Context, msg string, args ...interface{}) {
+	s.log("I", msg, args)
+}
+func (s *slogger) WarnContext(ctx context.Context, msg string, args ...interface{}) {
+	s.log("W", msg, args)
+}
+func (s *slogger) ErrorContext(ctx context.Context, msg string, args ...interface{}) {
+	s.log("E", msg, args)
+}
+
+func TestSlog(t *testing.T) {
+	s := &slogger{}
+	New(FromSlog(s)).Error(context.Background(), "failed", F("code", 500))
+	assert.Equal(t, []string{"E failed code500"}, s.calls)
+}
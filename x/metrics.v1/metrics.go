@@ -0,0 +1,95 @@
+/*
+包 github.com/qiniupd/qiniu-go-sdk/x/metrics.v1 为 SDK 提供可插拔的指标记录功能。
+
+默认不记录任何指标，调用方通过 SetRecorder 设置记录器后 SDK 才会开始记录：
+
+	registry := metrics.NewRegistry()
+	metrics.SetRecorder(registry)
+	http.Handle("/metrics", registry) // 以 Prometheus 文本格式导出
+
+也可以实现 Recorder 接口，将指标转发到 Prometheus 客户端或其他监控系统。
+*/
+package metrics
+
+import (
+	"sync/atomic"
+)
+
+// SDK 记录的指标名称
+const (
+	RequestDuration    = "qiniu_request_duration_seconds"     // 请求耗时，标签 service、api、host、code
+	TransferBytes      = "qiniu_transfer_bytes_total"         // 传输字节数，标签 service、direction
+	Retries            = "qiniu_retries_total"                // 重试次数，标签 service、api
+	HostFailures       = "qiniu_host_failures_total"          // 域名失败次数，标签 host
+	HostBlacklisted    = "qiniu_host_blacklisted_total"       // 域名因连续失败被屏蔽的次数，标签 host
+	PartUploadDuration = "qiniu_part_upload_duration_seconds" // 分片上传耗时，标签 host
+	JobQueueDepth      = "qiniu_syncdata_job_queue_depth"     // syncdata 上传服务排队中的任务数
+	JobsTotal          = "qiniu_syncdata_jobs_total"          // syncdata 上传服务结束的任务数，标签 state
+)
+
+// 指标标签
+type Label struct {
+	Name  string
+	Value string
+}
+
+// 创建标签
+func L(name, value string) Label {
+	return Label{Name: name, Value: value}
+}
+
+// 指标记录器，实现需要支持并发调用
+type Recorder interface {
+	// 计数器增加 delta
+	AddCounter(name string, delta float64, labels ...Label)
+	// 设置仪表盘的当前值
+	SetGauge(name string, value float64, labels ...Label)
+	// 向直方图中记录一次观测值
+	Observe(name string, value float64, labels ...Label)
+}
+
+type holder struct {
+	r Recorder
+}
+
+var current atomic.Value
+
+func init() {
+	current.Store(holder{})
+}
+
+// 设置全局记录器，传入 nil 则停止记录
+func SetRecorder(r Recorder) {
+	current.Store(holder{r})
+}
+
+// 返回全局记录器，未设置时返回 nil
+func GetRecorder() Recorder {
+	return current.Load().(holder).r
+}
+
+// 是否设置了记录器，调用方可以在构造标签前先检查，避免未启用时的开销
+func Enabled() bool {
+	return GetRecorder() != nil
+}
+
+// 使用全局记录器增加计数器
+func AddCounter(name string, delta float64, labels ...Label) {
+	if r := GetRecorder(); r != nil {
+		r.AddCounter(name, delta, labels...)
+	}
+}
+
+// 使用全局记录器设置仪表盘
+func SetGauge(name string, value float64, labels ...Label) {
+	if r := GetRecorder(); r != nil {
+		r.SetGauge(name, value, labels...)
+	}
+}
+
+// 使用全局记录器记录直方图观测值
+func Observe(name string, value float64, labels ...Label) {
+	if r := GetRecorder(); r != nil {
+		r.Observe(name, value, labels...)
+	}
+}
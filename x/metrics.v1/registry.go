@@ -0,0 +1,212 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 直方图默认的桶边界，单位为秒
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+type kind int
+
+const (
+	kindCounter kind = iota
+	kindGauge
+	kindHistogram
+)
+
+var kindNames = [...]string{"counter", "gauge", "histogram"}
+
+type series struct {
+	labels  []Label
+	value   float64 // 计数器和仪表盘的值，直方图的观测值之和
+	count   uint64
+	buckets []uint64
+}
+
+type family struct {
+	kind   kind
+	series map[string]*series
+}
+
+// Registry 是一个在内存中汇总指标的 Recorder，并能以 Prometheus 文本格式导出。
+// 同一个指标名只能用于一种指标类型，类型不一致的记录会被忽略。
+type Registry struct {
+	lock     sync.Mutex
+	buckets  []float64
+	families map[string]*family
+}
+
+// 创建使用默认桶边界的 Registry
+func NewRegistry() *Registry {
+	return NewRegistryWithBuckets(DefaultBuckets)
+}
+
+// 创建使用指定桶边界的 Registry，buckets 需按升序排列
+func NewRegistryWithBuckets(buckets []float64) *Registry {
+	b := make([]float64, len(buckets))
+	copy(b, buckets)
+	return &Registry{buckets: b, families: make(map[string]*family)}
+}
+
+func (r *Registry) AddCounter(name string, delta float64, labels ...Label) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if s := r.series(name, kindCounter, labels); s != nil {
+		s.value += delta
+	}
+}
+
+func (r *Registry) SetGauge(name string, value float64, labels ...Label) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if s := r.series(name, kindGauge, labels); s != nil {
+		s.value = value
+	}
+}
+
+func (r *Registry) Observe(name string, value float64, labels ...Label) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	s := r.series(name, kindHistogram, labels)
+	if s == nil {
+		return
+	}
+	if s.buckets == nil {
+		s.buckets = make([]uint64, len(r.buckets))
+	}
+	for i, le := range r.buckets {
+		if value <= le {
+			s.buckets[i]++
+		}
+	}
+	s.value += value
+	s.count++
+}
+
+// series 返回指定指标和标签对应的序列，调用方需持有锁
+func (r *Registry) series(name string, k kind, labels []Label) *series {
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{kind: k, series: make(map[string]*series)}
+		r.families[name] = f
+	} else if f.kind != k {
+		return nil
+	}
+	sorted := make([]Label, len(labels))
+	copy(sorted, labels)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	id := formatLabels(sorted)
+	s, ok := f.series[id]
+	if !ok {
+		s = &series{labels: sorted}
+		f.series[id] = s
+	}
+	return s
+}
+
+// 以 Prometheus 文本格式输出所有指标
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cw := &countWriter{w: bufio.NewWriter(w)}
+	for _, name := range names {
+		f := r.families[name]
+		ids := make([]string, 0, len(f.series))
+		for id := range f.series {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		cw.WriteString("# TYPE " + name + " " + kindNames[f.kind] + "\n")
+		for _, id := range ids {
+			s := f.series[id]
+			if f.kind != kindHistogram {
+				cw.WriteString(name + id + " " + formatFloat(s.value) + "\n")
+				continue
+			}
+			for i, le := range r.buckets {
+				cw.WriteString(name + "_bucket" + formatLabels(withLe(s.labels, formatFloat(le))) + " " + strconv.FormatUint(s.buckets[i], 10) + "\n")
+			}
+			cw.WriteString(name + "_bucket" + formatLabels(withLe(s.labels, "+Inf")) + " " + strconv.FormatUint(s.count, 10) + "\n")
+			cw.WriteString(name + "_sum" + id + " " + formatFloat(s.value) + "\n")
+			cw.WriteString(name + "_count" + id + " " + strconv.FormatUint(s.count, 10) + "\n")
+		}
+	}
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP 实现 http.Handler，可直接挂载为 /metrics 接口
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+type countWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countWriter) WriteString(s string) {
+	if c.err != nil {
+		return
+	}
+	n, err := c.w.WriteString(s)
+	c.n += int64(n)
+	c.err = err
+}
+
+func withLe(labels []Label, le string) []Label {
+	l := make([]Label, len(labels), len(labels)+1)
+	copy(l, labels)
+	return append(l, Label{Name: "le", Value: le})
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func formatLabels(labels []Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l.Name)
+		b.WriteString(`="`)
+		b.WriteString(labelValueEscaper.Replace(l.Value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistryWithBuckets([]float64{0.1, 1})
+	r.AddCounter("a_total", 1, L("host", "h1"))
+	r.AddCounter("a_total", 2, L("host", "h1"))
+	r.AddCounter("a_total", 1, L("host", `h"2`))
+	r.SetGauge("b", 3)
+	r.SetGauge("b", 5)
+	r.Observe("c_seconds", 0.05, L("y", "2"), L("x", "1"))
+	r.Observe("c_seconds", 0.5, L("x", "1"), L("y", "2"))
+	r.Observe("c_seconds", 2, L("x", "1"), L("y", "2"))
+	r.Observe("a_total", 1) // 类型不一致，忽略
+
+	var buf bytes.Buffer
+	n, err := r.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Fatal("WriteTo returns wrong length:", n, buf.Len())
+	}
+	expected := `# TYPE a_total counter
+a_total{host="h1"} 3
+a_total{host="h\"2"} 1
+# TYPE b gauge
+b 5
+# TYPE c_seconds histogram
+c_seconds_bucket{x="1",y="2",le="0.1"} 1
+c_seconds_bucket{x="1",y="2",le="1"} 2
+c_seconds_bucket{x="1",y="2",le="+Inf"} 3
+c_seconds_sum{x="1",y="2"} 2.55
+c_seconds_count{x="1",y="2"} 3
+`
+	if buf.String() != expected {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestAPI(t *testing.T) {
+	cases := map[string]string{
+		"":                                  "form",
+		"/":                                 "form",
+		"/stat/YnVja2V0OmtleQ==":            "stat",
+		"/list":                             "list",
+		"/buckets/b/objects/k/uploads/id/1": "multipart",
+		"/getfile/ak/bucket/key":            "getfile",
+		"/buckets":                          "buckets",
+		"/v4/query":                         "v4/query",
+		"/v2/bucketInfo":                    "v2/bucketInfo",
+		"/v6/domain/list":                   "v6/domain",
+		"/v4":                               "v4",
+		"/video/a.mp4":                      "video",
+	}
+	for path, api := range cases {
+		if got := API(path); got != api {
+			t.Errorf("API(%q) = %q, expected %q", path, got, api)
+		}
+	}
+}
+
+func TestGlobalRecorder(t *testing.T) {
+	defer SetRecorder(nil)
+	if Enabled() {
+		t.Fatal("recorder should be disabled by default")
+	}
+	AddCounter("x", 1)
+
+	r := NewRegistry()
+	SetRecorder(r)
+	if !Enabled() {
+		t.Fatal("recorder should be enabled")
+	}
+	AddCounter("x", 1)
+	var buf bytes.Buffer
+	r.WriteTo(&buf)
+	if buf.String() != "# TYPE x counter\nx 1\n" {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+}
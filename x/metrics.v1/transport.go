@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Transport 记录经过它的请求的耗时和传输字节数，未设置全局记录器时直接转发请求。
+// 耗时统计到收到响应头为止，下载的字节数在响应 body 读取结束或关闭时记录。
+type Transport struct {
+	Service   string // 服务名，如 up、rs、rsf、io、uc
+	Transport http.RoundTripper
+}
+
+func (t *Transport) NestedObject() interface{} {
+	return t.Transport
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if !Enabled() {
+		return transport.RoundTrip(req)
+	}
+
+	start := time.Now()
+	resp, err := transport.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	Observe(RequestDuration, time.Since(start).Seconds(),
+		L("service", t.Service), L("api", API(req.URL.Path)), L("host", req.URL.Host), L("code", code))
+	if req.ContentLength > 0 {
+		AddCounter(TransferBytes, float64(req.ContentLength), L("service", t.Service), L("direction", "up"))
+	}
+	if err == nil && resp.Body != nil {
+		resp.Body = &countingBody{ReadCloser: resp.Body, service: t.Service}
+	}
+	return resp, err
+}
+
+// API 根据请求路径返回用于指标标签的接口名，只取路径的第一段，避免对象名进入标签；
+// 第一段为 v1、v4 等版本号时再加上第二段，例如 /v4/query 为 v4/query
+func API(path string) string {
+	segs := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 3)
+	switch {
+	case segs[0] == "":
+		return "form"
+	case segs[0] == "buckets" && len(segs) > 1:
+		return "multipart"
+	case isVersion(segs[0]) && len(segs) > 1 && segs[1] != "":
+		return segs[0] + "/" + segs[1]
+	}
+	return segs[0]
+}
+
+func isVersion(seg string) bool {
+	if len(seg) < 2 || seg[0] != 'v' {
+		return false
+	}
+	for _, c := range seg[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+type countingBody struct {
+	io.ReadCloser
+	service  string
+	n        int64
+	reported int32
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err == io.EOF {
+		b.report()
+	}
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	b.report()
+	return b.ReadCloser.Close()
+}
+
+func (b *countingBody) report() {
+	if atomic.CompareAndSwapInt32(&b.reported, 0, 1) && b.n > 0 {
+		AddCounter(TransferBytes, float64(b.n), L("service", b.service), L("direction", "down"))
+	}
+}
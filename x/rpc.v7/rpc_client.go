@@ -6,7 +6,6 @@ import (
 	"errors"
 	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
 	"strings"
@@ -149,6 +148,17 @@ func (r Client) Do(ctx Context, req *http.Request) (resp *http.Response, err err
 	default:
 	}
 
+	if req.Context() == Background() {
+		// http.Client 设置了 Timeout 时会复制请求，CancelRequest 无法取消复制后的请求，
+		// 请求没有自己的 Context 时使用 ctx，ctx 取消时请求会被中断
+		req = req.WithContext(ctx)
+	}
+
+	span, info := startSpan(ctx, req)
+	defer func() {
+		finishSpan(span, info, resp, err)
+	}()
+
 	if tr, ok := getRequestCanceler(transport); ok { // support CancelRequest
 		reqC := make(chan bool, 1)
 		go func() {
@@ -269,10 +279,8 @@ func (r Client) CallWithForm(
 
 	resp, err := r.DoRequestWithForm(ctx, method, url1, param)
 	if err != nil {
-		log.Println("error is ", err)
 		return err
 	}
-	log.Println("ret code", resp.StatusCode)
 	return CallRet(ctx, ret, resp)
 }
 
@@ -0,0 +1,194 @@
+package rpc
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "context"
+)
+
+// --------------------------------------------------------------------
+
+// SpanInfo 描述一次 HTTP 调用，StartSpan 时只有请求相关的字段有值，
+// Finish 时补充响应状态、接收字节数和错误
+type SpanInfo struct {
+	Method        string
+	Path          string // 路径模板，如 /buckets/{bucket}/objects/{key}/uploads/{uploadId}/{partNumber}
+	Host          string
+	Attempt       int // 第几次尝试，从 1 开始
+	StatusCode    int
+	BytesSent     int64
+	BytesReceived int64
+	Err           error
+	StartTime     time.Time
+	EndTime       time.Time
+}
+
+// Span 代表一次进行中的 HTTP 调用
+type Span interface {
+	// 调用结束时被调用，对于成功的调用会在响应 body 读取完毕或关闭时调用
+	Finish(info *SpanInfo)
+}
+
+// Tracer 在每次 HTTP 调用前被调用，可以修改请求头以传播追踪上下文
+type Tracer interface {
+	StartSpan(ctx Context, req *http.Request, info *SpanInfo) Span
+}
+
+type tracerHolder struct {
+	t Tracer
+}
+
+var globalTracer atomic.Value
+
+func init() {
+	globalTracer.Store(tracerHolder{})
+}
+
+// 设置全局 Tracer，传入 nil 则关闭追踪
+func SetTracer(t Tracer) {
+	globalTracer.Store(tracerHolder{t})
+}
+
+type tracerKey struct{}
+type pathTemplateKey struct{}
+type attemptKey struct{}
+
+// 返回使用指定 Tracer 的 Context，优先于全局 Tracer
+func WithTracer(ctx Context, t Tracer) Context {
+	return WithValue(ctx, tracerKey{}, tracerHolder{t})
+}
+
+// 返回指定了路径模板的 Context，不指定时根据请求路径推断
+func WithPathTemplate(ctx Context, path string) Context {
+	return WithValue(ctx, pathTemplateKey{}, path)
+}
+
+// 返回记录了尝试次数的 Context，用于重试逻辑中标记当前是第几次尝试
+func WithAttempt(ctx Context, attempt int) Context {
+	return WithValue(ctx, attemptKey{}, attempt)
+}
+
+// 获取 Context 中记录的尝试次数，未记录时返回 1
+func AttemptFromContext(ctx Context) int {
+	if attempt, ok := ctx.Value(attemptKey{}).(int); ok && attempt > 0 {
+		return attempt
+	}
+	return 1
+}
+
+func tracerFromContext(ctx Context) Tracer {
+	if h, ok := ctx.Value(tracerKey{}).(tracerHolder); ok {
+		return h.t
+	}
+	return globalTracer.Load().(tracerHolder).t
+}
+
+// startSpan 在设置了 Tracer 时开始追踪一次调用，否则返回 nil
+func startSpan(ctx Context, req *http.Request) (Span, *SpanInfo) {
+	tracer := tracerFromContext(ctx)
+	if tracer == nil {
+		return nil, nil
+	}
+	path, ok := ctx.Value(pathTemplateKey{}).(string)
+	if !ok {
+		path = PathTemplate(req.URL.Path)
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	info := &SpanInfo{
+		Method:    req.Method,
+		Path:      path,
+		Host:      host,
+		Attempt:   AttemptFromContext(ctx),
+		BytesSent: req.ContentLength,
+		StartTime: time.Now(),
+	}
+	return tracer.StartSpan(ctx, req, info), info
+}
+
+// finishSpan 在调用失败时立即结束追踪，成功时在响应 body 读取完毕或关闭时结束
+func finishSpan(span Span, info *SpanInfo, resp *http.Response, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil || resp == nil || resp.Body == nil {
+		info.Err = err
+		info.EndTime = time.Now()
+		span.Finish(info)
+		return
+	}
+	info.StatusCode = resp.StatusCode
+	resp.Body = &tracedBody{ReadCloser: resp.Body, span: span, info: info}
+}
+
+type tracedBody struct {
+	io.ReadCloser
+	span Span
+	info *SpanInfo
+	once sync.Once
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.info.BytesReceived += int64(n)
+	if err == io.EOF {
+		b.finish(nil)
+	} else if err != nil {
+		b.finish(err)
+	}
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish(nil)
+	return err
+}
+
+func (b *tracedBody) finish(err error) {
+	b.once.Do(func() {
+		b.info.Err = err
+		b.info.EndTime = time.Now()
+		b.span.Finish(b.info)
+	})
+}
+
+// --------------------------------------------------------------------
+
+// PathTemplate 将请求路径中的存储空间、对象名等变量替换为占位符，避免追踪系统中出现过多不同的操作名。
+// 第一段路径作为接口名保留，之后的路径段只保留已知的字面量
+func PathTemplate(path string) string {
+	segs := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if segs[0] == "" {
+		return "/"
+	}
+	if segs[0] == "buckets" {
+		return bucketsPathTemplate(segs)
+	}
+	if len(segs) == 1 {
+		return "/" + segs[0]
+	}
+	return "/" + segs[0] + "/{...}"
+}
+
+// 分片上传 v2 的路径为 /buckets/{bucket}/objects/{key}/uploads[/{uploadId}[/{partNumber}]]
+func bucketsPathTemplate(segs []string) string {
+	names := []string{"{bucket}", "objects", "{key}", "uploads", "{uploadId}", "{partNumber}"}
+	var b strings.Builder
+	b.WriteString("/buckets")
+	for i := range segs[1:] {
+		if i >= len(names) {
+			break
+		}
+		b.WriteString("/")
+		b.WriteString(names[i])
+	}
+	return b.String()
+}
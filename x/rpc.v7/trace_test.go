@@ -0,0 +1,90 @@
+package rpc
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// --------------------------------------------------------------------
+
+func TestPathTemplate(t *testing.T) {
+
+	cases := map[string]string{
+		"":                                   "/",
+		"/":                                  "/",
+		"/list":                              "/list",
+		"/stat/YnVja2V0OmtleQ==":             "/stat/{...}",
+		"/mkblk/4194304":                     "/mkblk/{...}",
+		"/buckets/b/objects/a2V5/uploads":    "/buckets/{bucket}/objects/{key}/uploads",
+		"/buckets/b/objects/~/uploads/id/12": "/buckets/{bucket}/objects/{key}/uploads/{uploadId}/{partNumber}",
+	}
+	for path, tmpl := range cases {
+		assert.Equal(t, tmpl, PathTemplate(path), path)
+	}
+}
+
+func TestParseTraceParent(t *testing.T) {
+
+	sc, err := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "k=v")
+	assert.NoError(t, err)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.TraceParent())
+	assert.Equal(t, "k=v", sc.State)
+
+	for _, s := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+	} {
+		_, err = ParseTraceParent(s, "")
+		assert.Equal(t, ErrInvalidTraceParent, err, s)
+	}
+}
+
+func TestW3CTracer(t *testing.T) {
+
+	var header http.Header
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		header = req.Header
+		w.Write([]byte("hello"))
+	}))
+	defer svr.Close()
+
+	var spans []*W3CSpan
+	tracer := &W3CTracer{OnFinish: func(span *W3CSpan) { spans = append(spans, span) }}
+	parent, _ := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "k=v")
+
+	ctx := WithTracer(context.Background(), tracer)
+	ctx = WithSpanContext(ctx, parent)
+	ctx = WithAttempt(ctx, 2)
+	resp, err := DefaultClient.DoRequest(ctx, "GET", svr.URL+"/stat/YnVja2V0OmtleQ==")
+	assert.NoError(t, err)
+	assert.Len(t, spans, 0)
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	assert.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, parent.TraceID, span.Context.TraceID)
+	assert.NotEqual(t, parent.SpanID, span.Context.SpanID)
+	assert.Equal(t, span.Context.TraceParent(), header.Get("traceparent"))
+	assert.Equal(t, "k=v", header.Get("tracestate"))
+	assert.Equal(t, "/stat/{...}", span.Info.Path)
+	assert.Equal(t, 200, span.Info.StatusCode)
+	assert.Equal(t, 2, span.Info.Attempt)
+	assert.Equal(t, int64(5), span.Info.BytesReceived)
+
+	_, err = DefaultClient.DoRequest(WithTracer(context.Background(), tracer), "GET", "http://127.0.0.1:1/list")
+	assert.Error(t, err)
+	assert.Len(t, spans, 2)
+	assert.Error(t, spans[1].Info.Err)
+	assert.False(t, spans[1].Parent.IsValid())
+	assert.Equal(t, 1, spans[1].Info.Attempt)
+}
@@ -0,0 +1,123 @@
+package rpc
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+
+	. "context"
+)
+
+// --------------------------------------------------------------------
+
+var ErrInvalidTraceParent = errors.New("invalid traceparent")
+
+// W3C Trace Context 中的追踪上下文，参见 https://www.w3.org/TR/trace-context/
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+	State   string // tracestate 请求头，原样传播
+}
+
+// 是否为有效的追踪上下文，TraceID 和 SpanID 均不能全为 0
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// 返回 traceparent 请求头的值
+func (sc SpanContext) TraceParent() string {
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + hex.EncodeToString([]byte{sc.Flags})
+}
+
+// 解析 traceparent 和 tracestate 请求头
+func ParseTraceParent(traceparent, tracestate string) (sc SpanContext, err error) {
+	// version-traceid-spanid-flags
+	if len(traceparent) < 55 || traceparent[2] != '-' || traceparent[35] != '-' || traceparent[52] != '-' {
+		return sc, ErrInvalidTraceParent
+	}
+	version, err := hex.DecodeString(traceparent[:2])
+	if err != nil || version[0] == 0xff || (version[0] == 0 && len(traceparent) != 55) {
+		return sc, ErrInvalidTraceParent
+	}
+	if _, err = hex.Decode(sc.TraceID[:], []byte(traceparent[3:35])); err != nil {
+		return sc, ErrInvalidTraceParent
+	}
+	if _, err = hex.Decode(sc.SpanID[:], []byte(traceparent[36:52])); err != nil {
+		return sc, ErrInvalidTraceParent
+	}
+	var flags [1]byte
+	if _, err = hex.Decode(flags[:], []byte(traceparent[53:55])); err != nil {
+		return sc, ErrInvalidTraceParent
+	}
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return sc, ErrInvalidTraceParent
+	}
+	sc.State = tracestate
+	return sc, nil
+}
+
+type spanContextKey struct{}
+
+// 返回携带追踪上下文的 Context，之后的 HTTP 调用将作为该上下文的子 span
+func WithSpanContext(ctx Context, sc SpanContext) Context {
+	return WithValue(ctx, spanContextKey{}, sc)
+}
+
+// 获取 Context 中的追踪上下文
+func SpanContextFromContext(ctx Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+// --------------------------------------------------------------------
+
+// W3CTracer 为每次 HTTP 调用生成新的 span，并通过 traceparent 和 tracestate 请求头传播追踪上下文。
+// Context 中没有追踪上下文时会生成新的 trace id，且 span 默认被标记为采样
+type W3CTracer struct {
+	// 调用结束时被调用，可用于将 span 导出到追踪系统，可以为 nil
+	OnFinish func(span *W3CSpan)
+}
+
+// W3CTracer 生成的 span
+type W3CSpan struct {
+	Parent  SpanContext // 父 span，根 span 的父 span 无效
+	Context SpanContext
+	Info    SpanInfo
+	tracer  *W3CTracer
+}
+
+func (t *W3CTracer) StartSpan(ctx Context, req *http.Request, info *SpanInfo) Span {
+	span := &W3CSpan{tracer: t}
+	if parent, ok := SpanContextFromContext(ctx); ok && parent.IsValid() {
+		span.Parent = parent
+		span.Context.TraceID = parent.TraceID
+		span.Context.Flags = parent.Flags
+		span.Context.State = parent.State
+	} else {
+		randomBytes(span.Context.TraceID[:])
+		span.Context.Flags = 1
+	}
+	randomBytes(span.Context.SpanID[:])
+
+	req.Header.Set("traceparent", span.Context.TraceParent())
+	if span.Context.State != "" {
+		req.Header.Set("tracestate", span.Context.State)
+	}
+	return span
+}
+
+func (s *W3CSpan) Finish(info *SpanInfo) {
+	s.Info = *info
+	if s.tracer.OnFinish != nil {
+		s.tracer.OnFinish(s)
+	}
+}
+
+func randomBytes(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+}